@@ -0,0 +1,21 @@
+package builder
+
+// Container is the runtime independent state of the function container.
+type Container struct {
+	ID    string
+	Name  string
+	Image string
+	// Running is set if the container is started.
+	Running  bool
+	Labels   map[string]string
+	Env      []string
+	MemoryMB int
+	// HostIP and Port are the published address of the function port, Port is 0 if it is not published.
+	HostIP string
+	Port   int
+	// Addresses are the container IP addresses by network name.
+	Addresses map[string]string
+	Hardening Hardening
+	// TLSDir is the host directory with TLS files mounted into the container.
+	TLSDir string
+}
@@ -1,15 +1,22 @@
 package builder
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
 )
 
@@ -55,37 +62,221 @@ func (d Docker) ImageBuild(ctx context.Context, dst, name string) (string, error
 	return tag, nil
 }
 
+// Container labels of the function containers.
+const (
+	LabelNamespace = "lambda-go.namespace"
+	LabelFunction  = "lambda-go.function"
+	// LabelTLS marks containers serving gRPC with the certificate issued by the internal CA.
+	LabelTLS = "lambda-go.tls"
+	// LabelNetwork is the private network of the container without published ports.
+	LabelNetwork = "lambda-go.network"
+	// LabelSecurityOptOut lists the disabled parts of the hardening profile.
+	LabelSecurityOptOut = "lambda-go.security-opt-out"
+	// LabelEgress lists the allowed egress destinations of the container.
+	LabelEgress = "lambda-go.egress"
+)
+
+// FunctionPort is the port of the function gRPC server inside the container.
+const FunctionPort = "8080"
+
+// EnvTLSDir is the environment variable with the directory of the function server TLS files.
+const EnvTLSDir = "LAMBDA_TLS_DIR"
+
+// TLSMountPath is the path of the TLS directory mounted into the container.
+const TLSMountPath = "/run/lambda-tls"
+
+// ContainerOptions are the options of the function container.
+type ContainerOptions struct {
+	Image    string
+	Name     string
+	Port     int
+	HostIP   string
+	MemoryMB int
+	Labels   map[string]string
+	Env      []string
+	// Network attaches the container to the private network, the port is not published on the host then.
+	Network   string
+	Hardening Hardening
+	// TLSDir is the host directory with TLS files of the function server, it is mounted read-only
+	// and the path inside the container is passed in EnvTLSDir.
+	TLSDir string
+}
+
+// Hardening is the security profile of the container.
+type Hardening struct {
+	// User is the user and group the function runs as, image user is used if empty.
+	User           string
+	ReadOnlyRootfs bool
+	// TmpfsSizeMB is the size of writable /tmp when root filesystem is read-only.
+	TmpfsSizeMB     int
+	DropAllCaps     bool
+	NoNewPrivileges bool
+	// Seccomp is the seccomp profile JSON, empty means Docker default profile.
+	Seccomp string
+}
+
+// apply sets the security profile to the container config.
+func (h Hardening) apply(config *container.Config, hostConfig *container.HostConfig) {
+	config.User = h.User
+	hostConfig.ReadonlyRootfs = h.ReadOnlyRootfs
+
+	if h.ReadOnlyRootfs && h.TmpfsSizeMB > 0 {
+		hostConfig.Tmpfs = map[string]string{"/tmp": fmt.Sprintf("rw,noexec,nosuid,size=%dm", h.TmpfsSizeMB)}
+	}
+
+	if h.DropAllCaps {
+		hostConfig.CapDrop = []string{"ALL"}
+	}
+
+	if h.NoNewPrivileges {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "no-new-privileges:true")
+	}
+
+	if h.Seccomp != "" {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "seccomp="+h.Seccomp)
+	}
+}
+
+// inspectHardening returns the security profile of the existing container.
+func inspectHardening(data types.ContainerJSON) Hardening {
+	h := Hardening{User: data.Config.User}
+
+	if data.HostConfig == nil {
+		return h
+	}
+
+	h.ReadOnlyRootfs = data.HostConfig.ReadonlyRootfs
+	h.DropAllCaps = slices.Contains(data.HostConfig.CapDrop, "ALL")
+
+	if tmpfs, ok := data.HostConfig.Tmpfs["/tmp"]; ok {
+		for _, opt := range strings.Split(tmpfs, ",") {
+			if size, ok := strings.CutPrefix(opt, "size="); ok {
+				h.TmpfsSizeMB, _ = strconv.Atoi(strings.TrimSuffix(size, "m"))
+			}
+		}
+	}
+
+	for _, opt := range data.HostConfig.SecurityOpt {
+		switch {
+		case opt == "no-new-privileges:true" || opt == "no-new-privileges":
+			h.NoNewPrivileges = true
+		case strings.HasPrefix(opt, "seccomp="):
+			h.Seccomp = strings.TrimPrefix(opt, "seccomp=")
+		}
+	}
+
+	return h
+}
+
 // ContainerCreate creates Docker container.
-func (d Docker) ContainerCreate(ctx context.Context, image string, port int) (string, error) {
-	resp, err := d.cli.ContainerCreate(
-		ctx, &container.Config{
-			Image: image,
-			Cmd:   []string{},
-			Tty:   false,
+func (d Docker) ContainerCreate(ctx context.Context, opts ContainerOptions) (string, error) {
+	hostConfig := &container.HostConfig{
+		Resources: container.Resources{
+			Memory: int64(opts.MemoryMB) << 20,
 		},
-		&container.HostConfig{
-			PortBindings: nat.PortMap{
-				"8080/tcp": []nat.PortBinding{
-					{
-						HostIP:   "0.0.0.0",
-						HostPort: strconv.Itoa(port),
-					},
+	}
+
+	if opts.Network != "" {
+		hostConfig.NetworkMode = container.NetworkMode(opts.Network)
+	} else {
+		hostConfig.PortBindings = nat.PortMap{
+			FunctionPort + "/tcp": []nat.PortBinding{
+				{
+					HostIP:   opts.HostIP,
+					HostPort: strconv.Itoa(opts.Port),
 				},
 			},
-		},
+		}
+	}
+
+	config := &container.Config{
+		Image:  opts.Image,
+		Cmd:    []string{},
+		Tty:    false,
+		Labels: opts.Labels,
+		Env:    opts.Env,
+	}
+
+	opts.Hardening.apply(config, hostConfig)
+
+	if opts.TLSDir != "" {
+		hostConfig.Mounts = []mount.Mount{
+			{Type: mount.TypeBind, Source: opts.TLSDir, Target: TLSMountPath, ReadOnly: true},
+		}
+		config.Env = append(slices.Clone(config.Env), EnvTLSDir+"="+TLSMountPath)
+	}
+
+	resp, err := d.cli.ContainerCreate(
+		ctx,
+		config,
+		hostConfig,
 		nil,
 		nil,
-		"go-lambda",
+		opts.Name,
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to create container: %w", err)
 	}
 
-	d.logger.Info("container created", slog.String("id", resp.ID[:5]), slog.Int("port", port))
+	d.logger.Info(
+		"container created",
+		slog.String("id", resp.ID[:5]),
+		slog.Int("port", opts.Port),
+		slog.String("network", opts.Network),
+	)
 
 	return resp.ID, nil
 }
 
+// NetworkEnsure creates the bridge network if it does not exist.
+// Inter-container communication is disabled if isolated is set, so functions can not reach each other.
+// Internal network has no access outside of the host.
+func (d Docker) NetworkEnsure(ctx context.Context, name string, isolated, internal bool) error {
+	_, err := d.cli.NetworkInspect(ctx, name, types.NetworkInspectOptions{})
+	if err == nil {
+		return nil
+	}
+
+	if !client.IsErrNotFound(err) {
+		return fmt.Errorf("failed to inspect network: %w", err)
+	}
+
+	opts := types.NetworkCreate{
+		CheckDuplicate: true,
+		Driver:         "bridge",
+		Internal:       internal,
+		Labels:         map[string]string{LabelNetwork: name},
+	}
+
+	if isolated {
+		opts.Options = map[string]string{"com.docker.network.bridge.enable_icc": "false"}
+	}
+
+	if _, err := d.cli.NetworkCreate(ctx, name, opts); err != nil {
+		return fmt.Errorf("failed to create network: %w", err)
+	}
+
+	d.logger.Info("network created", slog.String("name", name))
+
+	return nil
+}
+
+// NetworkGateway returns the gateway address of the network, it is the host address reachable from containers.
+func (d Docker) NetworkGateway(ctx context.Context, name string) (string, error) {
+	res, err := d.cli.NetworkInspect(ctx, name, types.NetworkInspectOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect network: %w", err)
+	}
+
+	for _, cfg := range res.IPAM.Config {
+		if cfg.Gateway != "" {
+			return cfg.Gateway, nil
+		}
+	}
+
+	return "", fmt.Errorf("network %s has no gateway", name)
+}
+
 // ContainerStart starts Docker container.
 func (d Docker) ContainerStart(ctx context.Context, imageID string) error {
 	if err := d.cli.ContainerStart(ctx, imageID, types.ContainerStartOptions{}); err != nil {
@@ -108,22 +299,164 @@ func (d Docker) ContainerStop(ctx context.Context, imageID string) error {
 	return nil
 }
 
+// ContainerRemove removes Docker container, running container is killed.
+func (d Docker) ContainerRemove(ctx context.Context, containerID string) error {
+	if err := d.cli.ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{Force: true}); err != nil {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+
+	d.logger.Debug("container removed", slog.String("id", containerID[:5]))
+
+	return nil
+}
+
 // ContainersList lists all Docker containers.
-func (d Docker) ContainersList(ctx context.Context) ([]types.Container, error) {
+func (d Docker) ContainersList(ctx context.Context) ([]Container, error) {
 	containers, err := d.cli.ContainerList(ctx, types.ContainerListOptions{All: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list containers: %w", err)
 	}
 
-	return containers, nil
+	result := make([]Container, 0, len(containers))
+
+	for _, c := range containers {
+		var name string
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+
+		result = append(result, Container{
+			ID:      c.ID,
+			Name:    name,
+			Image:   c.Image,
+			Running: c.State == "running",
+			Labels:  c.Labels,
+		})
+	}
+
+	return result, nil
 }
 
 // ContainerInspect inspects Docker container.
-func (d Docker) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+func (d Docker) ContainerInspect(ctx context.Context, containerID string) (Container, error) {
 	data, err := d.cli.ContainerInspect(ctx, containerID)
 	if err != nil {
-		return types.ContainerJSON{}, fmt.Errorf("failed to inspect container: %w", err)
+		return Container{}, fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	c := Container{
+		ID:        data.ID,
+		Name:      strings.TrimPrefix(data.Name, "/"),
+		Addresses: make(map[string]string),
+		Hardening: inspectHardening(data),
+	}
+
+	if data.State != nil {
+		c.Running = data.State.Running
+	}
+
+	if data.Config != nil {
+		c.Image = data.Config.Image
+		c.Labels = data.Config.Labels
+
+		// the TLS directory variable is set by ContainerCreate from the mount.
+		for _, env := range data.Config.Env {
+			if !strings.HasPrefix(env, EnvTLSDir+"=") {
+				c.Env = append(c.Env, env)
+			}
+		}
+	}
+
+	for _, m := range data.Mounts {
+		if m.Type == mount.TypeBind && m.Destination == TLSMountPath {
+			c.TLSDir = m.Source
+		}
+	}
+
+	if hostConfig := data.HostConfig; hostConfig != nil {
+		c.MemoryMB = int(hostConfig.Memory >> 20)
+
+		for _, bindings := range hostConfig.PortBindings {
+			if len(bindings) == 0 {
+				continue
+			}
+
+			port, err := strconv.Atoi(bindings[0].HostPort)
+			if err != nil {
+				return Container{}, fmt.Errorf("failed to parse port: %w", err)
+			}
+
+			c.HostIP = bindings[0].HostIP
+			c.Port = port
+		}
+	}
+
+	if data.NetworkSettings != nil {
+		for network, settings := range data.NetworkSettings.Networks {
+			if settings != nil {
+				c.Addresses[network] = settings.IPAddress
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// ContainerLogs follows stdout and stderr of Docker container since the given time.
+// It calls fn for every line and returns when the container is stopped or context is canceled.
+func (d Docker) ContainerLogs(
+	ctx context.Context,
+	containerID string,
+	since time.Time,
+	fn func(stream, line string),
+) error {
+	rc, err := d.cli.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+		Since:      fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond()),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get container logs: %w", err)
 	}
+	defer rc.Close()
+
+	stdout, stdoutDone := lineWriter("stdout", fn)
+	stderr, stderrDone := lineWriter("stderr", fn)
+
+	_, err = stdcopy.StdCopy(stdout, stderr, rc)
+
+	stdout.Close()
+	stderr.Close()
+
+	<-stdoutDone
+	<-stderrDone
+
+	if err != nil {
+		return fmt.Errorf("failed to read container logs: %w", err)
+	}
+
+	return nil
+}
+
+// lineWriter returns writer which calls fn for every written line.
+// Returned channel is closed when all lines were processed after the writer was closed.
+func lineWriter(stream string, fn func(stream, line string)) (*io.PipeWriter, <-chan struct{}) {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		scanner := bufio.NewScanner(pr)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+		for scanner.Scan() {
+			fn(stream, scanner.Text())
+		}
+
+		_ = pr.CloseWithError(scanner.Err())
+	}()
 
-	return data, nil
+	return pw, done
 }
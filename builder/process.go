@@ -0,0 +1,487 @@
+package builder
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnvListenAddr is the environment variable with the listen address of the function gRPC server.
+const EnvListenAddr = "LAMBDA_LISTEN_ADDR"
+
+const (
+	// processStopTimeout is the time the function process has to exit after interrupt before it is killed.
+	processStopTimeout = 10 * time.Second
+	// processLogLines is the number of log lines kept for the running process.
+	processLogLines = 1000
+)
+
+// ErrContainerNotFound is returned for unknown container ID.
+var ErrContainerNotFound = errors.New("container not found")
+
+// Process is a runtime which builds the function with `go build` and runs it as a local child process.
+// Function gRPC server listens on the loopback port of the container options, networks and hardening
+// are not supported.
+type Process struct {
+	dir    string
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	procs map[string]*process
+}
+
+// process is the function process and its state file.
+type process struct {
+	mu   sync.Mutex
+	spec ContainerOptions
+	cmd  *exec.Cmd
+	// done is closed when the running process exits.
+	done chan struct{}
+	// lines are the recent log lines of the process, dropped is the number of lines removed from the head.
+	lines   []logLine
+	dropped int
+	// notify is closed and replaced on every new log line.
+	notify chan struct{}
+}
+
+type logLine struct {
+	time   time.Time
+	stream string
+	text   string
+}
+
+// NewProcess returns new Process runtime keeping binaries and container state in the directory.
+// Containers created before restart are loaded from the directory, they are stopped.
+func NewProcess(logger *slog.Logger, dir string) (*Process, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve runtime dir: %w", err)
+	}
+
+	for _, sub := range []string{"bin", "containers"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create runtime dir: %w", err)
+		}
+	}
+
+	p := &Process{dir: dir, logger: logger, procs: make(map[string]*process)}
+
+	files, err := filepath.Glob(filepath.Join(dir, "containers", "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read container: %w", err)
+		}
+
+		var spec ContainerOptions
+		if err := json.Unmarshal(data, &spec); err != nil {
+			return nil, fmt.Errorf("failed to parse container %s: %w", file, err)
+		}
+
+		id := strings.TrimSuffix(filepath.Base(file), ".json")
+		p.procs[id] = &process{spec: spec}
+	}
+
+	return p, nil
+}
+
+// ImageBuild builds the function binary from the Go module in dst, binary path is returned as the image.
+func (p *Process) ImageBuild(ctx context.Context, dst, name string) (string, error) {
+	bin := filepath.Join(p.dir, "bin", name)
+	tmp := bin + ".build"
+
+	cmd := exec.CommandContext(ctx, "go", "build", "-o", tmp, ".")
+	cmd.Dir = dst
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0")
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to build binary: %w: %s", err, out)
+	}
+
+	// binary is replaced by rename, so the running process of the previous build keeps its file.
+	if err := os.Rename(tmp, bin); err != nil {
+		return "", fmt.Errorf("failed to replace binary: %w", err)
+	}
+
+	p.logger.Debug("binary built", slog.String("path", bin))
+
+	return bin, nil
+}
+
+// ContainerCreate registers the function process, it is started by ContainerStart.
+// Hardening and memory limit of the options are ignored, the process runs as the lambda-go user.
+func (p *Process) ContainerCreate(_ context.Context, opts ContainerOptions) (string, error) {
+	if opts.Network != "" {
+		return "", errors.New("networks are not supported by process runtime")
+	}
+
+	id, err := newContainerID()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal container: %w", err)
+	}
+
+	if err := os.WriteFile(p.specPath(id), data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write container: %w", err)
+	}
+
+	p.mu.Lock()
+	p.procs[id] = &process{spec: opts}
+	p.mu.Unlock()
+
+	p.logger.Info("container created", slog.String("id", id[:5]), slog.Int("port", opts.Port))
+
+	return id, nil
+}
+
+// ContainerStart starts the function process.
+// The port is probed first, so the port conflict is reported by start as it is by Docker.
+func (p *Process) ContainerStart(_ context.Context, containerID string) error {
+	proc, err := p.get(containerID)
+	if err != nil {
+		return err
+	}
+
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+
+	if proc.running() {
+		return nil
+	}
+
+	addr := net.JoinHostPort(proc.spec.HostIP, strconv.Itoa(proc.spec.Port))
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	_ = lis.Close()
+
+	// the process does not inherit the environment of the control plane, as the container does not.
+	cmd := exec.Command(proc.spec.Image)
+	cmd.Env = append([]string{"PATH=" + os.Getenv("PATH"), EnvListenAddr + "=" + addr}, proc.spec.Env...)
+
+	// the process reads TLS files from the host directory, there is nothing to mount.
+	if proc.spec.TLSDir != "" {
+		cmd.Env = append(cmd.Env, EnvTLSDir+"="+proc.spec.TLSDir)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	proc.cmd = cmd
+	proc.done = make(chan struct{})
+	proc.lines = nil
+	proc.dropped = 0
+	proc.notify = make(chan struct{})
+
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
+	go func() { defer wg.Done(); proc.collect("stdout", stdout) }()
+	go func() { defer wg.Done(); proc.collect("stderr", stderr) }()
+
+	go func(done chan struct{}) {
+		// pipes must be drained before Wait closes them.
+		wg.Wait()
+		_ = cmd.Wait()
+		close(done)
+	}(proc.done)
+
+	p.logger.Debug("container started", slog.String("id", containerID[:5]), slog.Int("pid", cmd.Process.Pid))
+
+	return nil
+}
+
+// ContainerStop interrupts the function process and kills it if it does not exit in time.
+func (p *Process) ContainerStop(_ context.Context, containerID string) error {
+	proc, err := p.get(containerID)
+	if err != nil {
+		return err
+	}
+
+	if err := proc.stop(false); err != nil {
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+
+	p.logger.Debug("container stopped", slog.String("id", containerID[:5]))
+
+	return nil
+}
+
+// ContainerRemove removes the function process, running process is killed.
+func (p *Process) ContainerRemove(_ context.Context, containerID string) error {
+	proc, err := p.get(containerID)
+	if err != nil {
+		return err
+	}
+
+	if err := proc.stop(true); err != nil {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+
+	if err := os.Remove(p.specPath(containerID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+
+	p.mu.Lock()
+	delete(p.procs, containerID)
+	p.mu.Unlock()
+
+	p.logger.Debug("container removed", slog.String("id", containerID[:5]))
+
+	return nil
+}
+
+// ContainersList lists all function processes.
+func (p *Process) ContainersList(_ context.Context) ([]Container, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := make([]Container, 0, len(p.procs))
+
+	for id, proc := range p.procs {
+		result = append(result, proc.container(id))
+	}
+
+	return result, nil
+}
+
+// ContainerInspect inspects the function process.
+func (p *Process) ContainerInspect(_ context.Context, containerID string) (Container, error) {
+	proc, err := p.get(containerID)
+	if err != nil {
+		return Container{}, err
+	}
+
+	return proc.container(containerID), nil
+}
+
+// ContainerLogs follows stdout and stderr of the function process since the given time.
+// It calls fn for every line and returns when the process exits or context is canceled.
+func (p *Process) ContainerLogs(
+	ctx context.Context,
+	containerID string,
+	since time.Time,
+	fn func(stream, line string),
+) error {
+	proc, err := p.get(containerID)
+	if err != nil {
+		return err
+	}
+
+	proc.mu.Lock()
+	run := proc.done
+	proc.mu.Unlock()
+
+	if run == nil {
+		return nil
+	}
+
+	var cursor int
+
+	for {
+		proc.mu.Lock()
+
+		// the process was restarted, its logs are followed by the new call.
+		if proc.done != run {
+			proc.mu.Unlock()
+			return nil
+		}
+
+		if cursor < proc.dropped {
+			cursor = proc.dropped
+		}
+
+		lines := append([]logLine(nil), proc.lines[cursor-proc.dropped:]...)
+		cursor += len(lines)
+		notify := proc.notify
+
+		proc.mu.Unlock()
+
+		for _, line := range lines {
+			if !line.time.Before(since) {
+				fn(line.stream, line.text)
+			}
+		}
+
+		select {
+		case <-notify:
+		case <-run:
+			// output is collected completely before exit, so the rest of lines is read by the next iteration.
+			if proc.drained(cursor) {
+				return nil
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// NetworkEnsure is a no-op, function processes listen on the loopback.
+func (p *Process) NetworkEnsure(context.Context, string, bool, bool) error {
+	return nil
+}
+
+// NetworkGateway is not supported by process runtime.
+func (p *Process) NetworkGateway(context.Context, string) (string, error) {
+	return "", errors.New("networks are not supported by process runtime")
+}
+
+func (p *Process) get(containerID string) (*process, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	proc, ok := p.procs[containerID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrContainerNotFound, containerID)
+	}
+
+	return proc, nil
+}
+
+func (p *Process) specPath(containerID string) string {
+	return filepath.Join(p.dir, "containers", containerID+".json")
+}
+
+// running reports whether the process is started and not exited. Must be called with the lock held.
+func (proc *process) running() bool {
+	if proc.done == nil {
+		return false
+	}
+
+	select {
+	case <-proc.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// stop interrupts the process and kills it after timeout, the process is killed at once if kill is set.
+// The lock is not held while waiting, as the output is collected until the process exits.
+func (proc *process) stop(kill bool) error {
+	proc.mu.Lock()
+
+	if !proc.running() {
+		proc.mu.Unlock()
+		return nil
+	}
+
+	cmd, done := proc.cmd, proc.done
+
+	proc.mu.Unlock()
+
+	if kill {
+		_ = cmd.Process.Kill()
+		<-done
+
+		return nil
+	}
+
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		return err
+	}
+
+	select {
+	case <-done:
+	case <-time.After(processStopTimeout):
+		_ = cmd.Process.Kill()
+		<-done
+	}
+
+	return nil
+}
+
+// collect appends output lines of the process.
+func (proc *process) collect(stream string, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		proc.mu.Lock()
+
+		proc.lines = append(proc.lines, logLine{time: time.Now(), stream: stream, text: scanner.Text()})
+
+		if len(proc.lines) > processLogLines {
+			n := len(proc.lines) - processLogLines
+			proc.lines = append([]logLine(nil), proc.lines[n:]...)
+			proc.dropped += n
+		}
+
+		close(proc.notify)
+		proc.notify = make(chan struct{})
+
+		proc.mu.Unlock()
+	}
+}
+
+// drained reports whether all log lines were read by the cursor.
+func (proc *process) drained(cursor int) bool {
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+
+	return cursor >= proc.dropped+len(proc.lines)
+}
+
+func (proc *process) container(id string) Container {
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+
+	return Container{
+		ID:       id,
+		Name:     proc.spec.Name,
+		Image:    proc.spec.Image,
+		Running:  proc.running(),
+		Labels:   proc.spec.Labels,
+		Env:      proc.spec.Env,
+		MemoryMB: proc.spec.MemoryMB,
+		HostIP:   proc.spec.HostIP,
+		Port:     proc.spec.Port,
+		TLSDir:   proc.spec.TLSDir,
+	}
+}
+
+func newContainerID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate container id: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
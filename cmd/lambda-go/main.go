@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -26,22 +27,75 @@ func main() {
 
 	logger := cfg.InitSlog(conf.Log, cfg.GetVersion(), true)
 
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	shutdownTracing, err := lambda.InitTracing(ctx, conf.Trace)
 	if err != nil {
-		slog.Error("new client", "err", err)
+		slog.Error("init tracing", "err", err)
 		return
 	}
 
-	bld := builder.NewDocker(logger, cli)
-	svc := lambda.NewService(conf, logger, bld)
-	edp := lambda.NewEndpoint(svc, logger, conf.App.ServerAddr)
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("shutdown tracing", "err", err)
+		}
+	}()
+
+	rt, err := newRuntime(conf, logger)
+	if err != nil {
+		slog.Error("new runtime", "err", err)
+		return
+	}
+
+	svc, err := lambda.NewService(conf, logger, rt)
+	if err != nil {
+		slog.Error("new service", "err", err)
+		return
+	}
+
+	edp, err := lambda.NewEndpoint(conf, svc, logger)
+	if err != nil {
+		slog.Error("new endpoint", "err", err)
+		return
+	}
 
 	if err := svc.Init(ctx); err != nil {
 		slog.Error("failed to init service", "err", err)
 		return
 	}
 
+	if conf.App.EgressProxyAddr != "" {
+		go func() {
+			if err := svc.StartEgressProxy(ctx); err != nil {
+				slog.Error("egress proxy", "err", err)
+			}
+		}()
+	}
+
+	if conf.App.GRPCAddr != "" {
+		go func() {
+			if err := edp.StartGRPCServer(ctx); err != nil {
+				slog.Error("grpc server", "err", err)
+			}
+		}()
+	}
+
 	if err := edp.StartServer(ctx); err != nil {
 		slog.Error("run", "err", err)
 	}
 }
+
+// newRuntime returns the configured function runtime backend.
+func newRuntime(conf *config.Config, logger *slog.Logger) (lambda.Runtime, error) {
+	switch conf.App.Runtime {
+	case lambda.RuntimeDocker:
+		cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+		if err != nil {
+			return nil, fmt.Errorf("new client: %w", err)
+		}
+
+		return builder.NewDocker(logger, cli), nil
+	case lambda.RuntimeProcess:
+		return builder.NewProcess(logger, conf.App.RuntimeDir)
+	default:
+		return nil, fmt.Errorf("unknown runtime %q", conf.App.Runtime)
+	}
+}
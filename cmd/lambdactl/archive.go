@@ -0,0 +1,170 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+var mainTemplate = template.Must(template.New("main.go").Parse(`package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ihippik/lambda-go/lambda"
+)
+
+type Data struct {
+	Name string ` + "`json:\"name\"`" + `
+}
+
+// handle is the {{.Name}} function handler.
+func handle(_ context.Context, data []byte) ([]byte, error) {
+	var req Data
+
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&req); err != nil {
+		return nil, err
+	}
+
+	return []byte(fmt.Sprintf("Hello %s!", req.Name)), nil
+}
+
+func main() {
+	lambda.Start(handle)
+}
+`))
+
+var modTemplate = template.Must(template.New("go.mod").Parse(`module {{.Name}}
+
+go 1.21
+`))
+
+// scaffold writes main.go and go.mod of the new function, existing files are not overwritten.
+func scaffold(dir, name string) error {
+	if name == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+
+		name = filepath.Base(abs)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for _, tmpl := range []*template.Template{mainTemplate, modTemplate} {
+		path := filepath.Join(dir, tmpl.Name())
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%s already exists", path)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = tmpl.Execute(f, struct{ Name string }{name})
+
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return fmt.Errorf("write %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// pack returns tar.gz archive of the function directory, hidden files are skipped as the server skips them.
+func pack(dir string) ([]byte, error) {
+	if _, err := os.Stat(filepath.Join(dir, "main.go")); err != nil {
+		return nil, fmt.Errorf("function main.go: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+
+		hdr.Name = filepath.ToSlash(rel)
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
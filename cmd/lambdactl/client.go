@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/ihippik/lambda-go/lambda"
+)
+
+// Environment variables with the defaults of the common flags.
+const (
+	envServer    = "LAMBDACTL_SERVER"
+	envAPIKey    = "LAMBDACTL_API_KEY"
+	envNamespace = "LAMBDACTL_NAMESPACE"
+)
+
+// client is the lambda-go HTTP API client.
+type client struct {
+	server    string
+	apiKey    string
+	namespace string
+	http      *http.Client
+}
+
+// bindFlags adds the common flags of the API client to the command flags.
+func (c *client) bindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.server, "server", envOr(envServer, "http://localhost:9000"), "lambda-go API URL")
+	fs.StringVar(&c.apiKey, "api-key", os.Getenv(envAPIKey), "API key or bearer token")
+	fs.StringVar(&c.namespace, "n", os.Getenv(envNamespace), "function namespace")
+}
+
+// split returns namespace and name of the function, name could be qualified as "namespace/name".
+func (c *client) split(name string) (string, string) {
+	if namespace, short, ok := strings.Cut(name, "/"); ok {
+		return namespace, short
+	}
+
+	return c.namespace, name
+}
+
+// functionPath returns API path of the function.
+func (c *client) functionPath(name string) string {
+	namespace, name := c.split(name)
+	return namespacePath(namespace) + "/" + url.PathEscape(name)
+}
+
+// namespacePath returns API path of the namespace functions, empty namespace is the default one.
+func namespacePath(namespace string) string {
+	if namespace == "" {
+		return "/lambda"
+	}
+
+	return "/ns/" + url.PathEscape(namespace) + "/lambda"
+}
+
+// list returns functions of the namespace.
+func (c *client) list(ctx context.Context, namespace string) ([]lambda.FunctionInfo, error) {
+	var functions []lambda.FunctionInfo
+
+	if err := c.getJSON(ctx, namespacePath(namespace), &functions); err != nil {
+		return nil, err
+	}
+
+	return functions, nil
+}
+
+// exists reports whether the function is registered.
+func (c *client) exists(ctx context.Context, name string) (bool, error) {
+	namespace, name := c.split(name)
+
+	functions, err := c.list(ctx, namespace)
+	if err != nil {
+		return false, err
+	}
+
+	for _, fn := range functions {
+		if fn.Name == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// delete deletes the function.
+func (c *client) delete(ctx context.Context, name string) error {
+	resp, err := c.do(ctx, http.MethodDelete, c.functionPath(name), nil, nil)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// do sends the request and returns the response with 2xx status, error response body is returned as error.
+func (c *client) do(ctx context.Context, method, path string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.server, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return resp, nil
+}
+
+// getJSON decodes JSON response of GET request.
+func (c *client) getJSON(ctx context.Context, path string, v any) error {
+	resp, err := c.do(ctx, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// create uploads the function archive, the function is built before the response.
+func (c *client) create(ctx context.Context, name string, archive []byte, fields map[string]string) error {
+	return c.upload(ctx, c.functionPath(name)+"/create", archive, fields)
+}
+
+// update uploads the new version of the existing function, it is swapped with the running one after the build.
+func (c *client) update(ctx context.Context, name string, archive []byte, fields map[string]string) error {
+	return c.upload(ctx, c.functionPath(name)+"/update", archive, fields)
+}
+
+// upload sends the function archive and form fields to the path.
+func (c *client) upload(ctx context.Context, path string, archive []byte, fields map[string]string) error {
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+
+	for key, value := range fields {
+		if value == "" {
+			continue
+		}
+
+		if err := mw.WriteField(key, value); err != nil {
+			return err
+		}
+	}
+
+	part := textproto.MIMEHeader{}
+	part.Set("Content-Disposition", `form-data; name="file"; filename="func.tar.gz"`)
+	part.Set("Content-Type", "application/gzip")
+
+	w, err := mw.CreatePart(part)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(archive); err != nil {
+		return err
+	}
+
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	resp, err := c.do(
+		ctx,
+		http.MethodPost,
+		path,
+		&body,
+		http.Header{"Content-Type": {mw.FormDataContentType()}},
+	)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// invoke invokes the function and copies its response to w, invocation ID is returned.
+func (c *client) invoke(ctx context.Context, name string, payload io.Reader, stream bool, w io.Writer) (string, error) {
+	path := c.functionPath(name) + "/invoke"
+	if stream {
+		path += "-stream"
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, path, payload, http.Header{"Content-Type": {"application/json"}})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return "", err
+	}
+
+	return resp.Header.Get("X-Lambda-Invocation-Id"), nil
+}
+
+// logs calls fn for the log entries of the function, new entries are followed until context is canceled.
+func (c *client) logs(ctx context.Context, name string, query url.Values, follow bool, fn func(lambda.LogEntry)) error {
+	if follow {
+		query.Set("follow", "true")
+	}
+
+	path := c.functionPath(name) + "/logs"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if !follow {
+		var entries []lambda.LogEntry
+
+		if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			fn(entry)
+		}
+
+		return nil
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+
+		var entry lambda.LogEntry
+
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return fmt.Errorf("decode log entry: %w", err)
+		}
+
+		fn(entry)
+	}
+
+	if err := scanner.Err(); err != nil && !errors.Is(ctx.Err(), context.Canceled) {
+		return err
+	}
+
+	return nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	return fallback
+}
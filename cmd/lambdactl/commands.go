@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ihippik/lambda-go/lambda"
+)
+
+// runInit scaffolds the function in the directory.
+func runInit(_ context.Context, args []string) error {
+	fs, _ := newFlagSet("init")
+	name := fs.String("name", "", "function name (directory name by default)")
+
+	positional, err := parseFlags(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	dir := "."
+	if len(positional) == 1 {
+		dir = positional[0]
+	}
+
+	if err := scaffold(dir, *name); err != nil {
+		return err
+	}
+
+	fmt.Printf("function scaffolded in %s, run `go mod tidy` there to add dependencies\n", dir)
+
+	return nil
+}
+
+// runDeploy packages the function directory and uploads it, the server builds the function before the response.
+func runDeploy(ctx context.Context, args []string) error {
+	fs, c := newFlagSet("deploy")
+	name := fs.String("name", "", "function name (directory name by default)")
+	replace := fs.Bool("replace", false, "update the existing function")
+	follow := fs.Bool("logs", false, "follow function logs after deploy")
+	optOut := fs.String("security-opt-out", "", "comma-separated hardening opt-outs (admin only)")
+	noEgress := fs.Bool("no-egress", false, "deny egress of the function")
+	egress := fs.String("egress", "", "comma-separated allowed egress destinations")
+
+	positional, err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	dir := positional[0]
+
+	if *name == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+
+		*name = filepath.Base(abs)
+	}
+
+	// create keeps the existing function as is, so it is updated on explicit request only.
+	exists, err := c.exists(ctx, *name)
+	if err != nil {
+		return fmt.Errorf("check function: %w", err)
+	}
+
+	if exists && !*replace {
+		return fmt.Errorf("function %s already exists, use -replace to redeploy it", *name)
+	}
+
+	archive, err := pack(dir)
+	if err != nil {
+		return fmt.Errorf("package: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "uploading %s (%d bytes), waiting for build...\n", *name, len(archive))
+
+	fields := map[string]string{"security_opt_out": *optOut, "egress": *egress}
+	if *noEgress {
+		fields["no_egress"] = "true"
+	}
+
+	upload := c.create
+	if exists {
+		// the running function keeps serving until the new version is built.
+		upload = c.update
+	}
+
+	if err := upload(ctx, *name, archive, fields); err != nil {
+		return fmt.Errorf("deploy: %w", err)
+	}
+
+	fmt.Printf("function %s deployed\n", *name)
+
+	if !*follow {
+		return nil
+	}
+
+	return c.logs(ctx, *name, url.Values{}, true, printLog)
+}
+
+// runInvoke invokes the function with payload from the flag, file or stdin ("-").
+func runInvoke(ctx context.Context, args []string) error {
+	fs, c := newFlagSet("invoke")
+	data := fs.String("data", "", "request payload")
+	file := fs.String("file", "", "file with request payload, - for stdin")
+	stream := fs.Bool("stream", false, "print the response as it is streamed")
+
+	positional, err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	if *data != "" && *file != "" {
+		return errors.New("invoke: -data and -file are mutually exclusive")
+	}
+
+	var payload io.Reader = strings.NewReader(*data)
+
+	switch *file {
+	case "":
+	case "-":
+		payload = os.Stdin
+	default:
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		payload = f
+	}
+
+	id, err := c.invoke(ctx, positional[0], payload, *stream, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "\ninvocation %s\n", id)
+
+	return nil
+}
+
+// runList prints functions of the namespace.
+func runList(ctx context.Context, args []string) error {
+	fs, c := newFlagSet("list")
+
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	functions, err := c.list(ctx, c.namespace)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "NAMESPACE\tNAME\tCONTAINER\tADDRESS")
+
+	for _, fn := range functions {
+		address := fn.Network
+		if fn.Port != 0 {
+			address = fmt.Sprintf("port %d", fn.Port)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%.12s\t%s\n", fn.Namespace, fn.Name, fn.ContainerID, address)
+	}
+
+	return tw.Flush()
+}
+
+// runDelete deletes the function.
+func runDelete(ctx context.Context, args []string) error {
+	fs, c := newFlagSet("delete")
+
+	positional, err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	if err := c.delete(ctx, positional[0]); err != nil {
+		return err
+	}
+
+	fmt.Printf("function %s deleted\n", positional[0])
+
+	return nil
+}
+
+// runLogs prints function logs, new entries are printed until interrupted with -f.
+func runLogs(ctx context.Context, args []string) error {
+	fs, c := newFlagSet("logs")
+	follow := fs.Bool("f", false, "follow new log entries")
+	since := fs.String("since", "", "RFC3339 time or duration, e.g. 10m")
+	invocation := fs.String("invocation", "", "invocation ID")
+
+	positional, err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+
+	if *since != "" {
+		query.Set("since", *since)
+	}
+
+	if *invocation != "" {
+		query.Set("invocation", *invocation)
+	}
+
+	return c.logs(ctx, positional[0], query, *follow, printLog)
+}
+
+func printLog(entry lambda.LogEntry) {
+	id := entry.InvocationID
+	if id == "" {
+		id = "-"
+	}
+
+	fmt.Printf("%s %s %s %s\n", entry.Time.Format("2006-01-02T15:04:05.000Z07:00"), entry.Stream, id, entry.Message)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	cfg "github.com/ihippik/config"
+	"github.com/sethvargo/go-envconfig"
+
+	"github.com/ihippik/lambda-go/builder"
+	"github.com/ihippik/lambda-go/config"
+	"github.com/ihippik/lambda-go/lambda"
+)
+
+// devDebounce is the quiet period after the last source change before the rebuild.
+const devDebounce = 300 * time.Millisecond
+
+// runDev serves the function from the source directory with the local process runtime
+// and rebuilds it on every source change, the running instance is swapped after the successful build.
+func runDev(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lambdactl dev", flag.ContinueOnError)
+	name := fs.String("name", "", "function name (directory name by default)")
+	addr := fs.String("addr", "127.0.0.1:9000", "API listen address")
+
+	positional, err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	dir, err := filepath.Abs(positional[0])
+	if err != nil {
+		return err
+	}
+
+	if *name == "" {
+		*name = filepath.Base(dir)
+	}
+
+	tmp, err := os.MkdirTemp("", "lambdactl-dev")
+	if err != nil {
+		return fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	conf, err := devConfig(ctx, *addr, tmp)
+	if err != nil {
+		return err
+	}
+
+	logger := cfg.InitSlog(conf.Log, cfg.GetVersion(), false)
+
+	rt, err := builder.NewProcess(logger, conf.App.RuntimeDir)
+	if err != nil {
+		return fmt.Errorf("new runtime: %w", err)
+	}
+
+	svc, err := lambda.NewService(conf, logger, rt)
+	if err != nil {
+		return fmt.Errorf("new service: %w", err)
+	}
+
+	edp, err := lambda.NewEndpoint(conf, svc, logger)
+	if err != nil {
+		return fmt.Errorf("new endpoint: %w", err)
+	}
+
+	if err := svc.Init(ctx); err != nil {
+		return fmt.Errorf("init service: %w", err)
+	}
+
+	key := lambda.DefaultNamespace + "/" + *name
+
+	tail, unsubscribe := svc.TailLogs(key)
+	defer unsubscribe()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case entry := <-tail:
+				printLog(entry)
+			}
+		}
+	}()
+
+	// the function process is stopped on exit, the server context is canceled by then.
+	defer func() {
+		if err := svc.Delete(context.WithoutCancel(ctx), key); err != nil && !errors.Is(err, lambda.ErrFunctionNotFound) {
+			fmt.Fprintln(os.Stderr, "delete function:", err)
+		}
+	}()
+
+	lis, err := net.Listen("tcp", conf.App.ServerAddr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- edp.Serve(ctx, lis)
+	}()
+
+	fmt.Fprintf(os.Stderr, "serving %s on http://%s%s/invoke\n", *name, lis.Addr(), namespacePath("")+"/"+*name)
+
+	deploy(ctx, svc, key, dir)
+
+	if err := watch(ctx, dir, func() { deploy(ctx, svc, key, dir) }); err != nil {
+		return fmt.Errorf("watch: %w", err)
+	}
+
+	if err := <-done; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// devConfig returns the server configuration of the dev mode, all its state is kept in the temporary dir,
+// so the dev server never touches API keys, routes or the CA of the server started from the same directory.
+// Authentication is disabled, the API is served to the developer only.
+// Settings not forced by the dev mode are read from the environment as by lambda-go server.
+func devConfig(ctx context.Context, addr, dir string) (*config.Config, error) {
+	forced := envconfig.MapLookuper(map[string]string{
+		"APP_SERVER_ADDR":  addr,
+		"APP_RUNTIME":      lambda.RuntimeProcess,
+		"APP_RUNTIME_DIR":  filepath.Join(dir, "runtime"),
+		"APP_BUILD_DIR":    filepath.Join(dir, "build"),
+		"APP_NETWORK_MODE": "host-port",
+		"APP_AUDIT_FILE":   filepath.Join(dir, "audit.jsonl"),
+		"APP_ROUTES_FILE":  filepath.Join(dir, "routes.json"),
+		"APP_QUOTAS_FILE":  filepath.Join(dir, "quotas.json"),
+		"AUTH_ENABLED":     "false",
+		"AUTH_KEYS_FILE":   filepath.Join(dir, "api_keys.json"),
+		"TLS_CA_DIR":       filepath.Join(dir, "ca"),
+	})
+
+	defaults := envconfig.MapLookuper(map[string]string{
+		"LOG_LEVEL": "error",
+		"LOG_FMT":   "text",
+	})
+
+	var conf config.Config
+
+	if err := envconfig.ProcessWith(ctx, &conf, envconfig.MultiLookuper(forced, envconfig.OsLookuper(), defaults)); err != nil {
+		return nil, fmt.Errorf("process env: %w", err)
+	}
+
+	if err := os.MkdirAll(conf.App.BuildDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create build dir: %w", err)
+	}
+
+	return &conf, nil
+}
+
+// deploy builds the function from the directory, the running instance keeps serving if the build fails.
+func deploy(ctx context.Context, svc *lambda.Service, key, dir string) {
+	start := time.Now()
+
+	archive, err := pack(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "package:", err)
+		return
+	}
+
+	file := func() io.ReadCloser { return io.NopCloser(bytes.NewReader(archive)) }
+
+	err = svc.Update(ctx, key, file(), lambda.CreateOptions{})
+	if errors.Is(err, lambda.ErrFunctionNotFound) {
+		err = svc.Create(ctx, key, file(), lambda.CreateOptions{})
+	}
+
+	if err != nil {
+		if ctx.Err() == nil {
+			fmt.Fprintln(os.Stderr, "build failed:", err)
+		}
+
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "built in %s\n", time.Since(start).Round(time.Millisecond))
+}
+
+// watch calls fn after the changes of the directory tree until context is canceled, hidden entries are ignored.
+// Changes are debounced, so the editor saving several files triggers the single rebuild.
+func watch(ctx context.Context, dir string, fn func()) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err := watchTree(w, dir); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(devDebounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-w.Errors:
+			fmt.Fprintln(os.Stderr, "watch:", err)
+		case event := <-w.Events:
+			if hidden(dir, event.Name) || event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			// new directories are not watched by fsnotify recursively.
+			if event.Op.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watchTree(w, event.Name); err != nil {
+						fmt.Fprintln(os.Stderr, "watch:", err)
+					}
+				}
+			}
+
+			timer.Reset(devDebounce)
+		case <-timer.C:
+			fn()
+		}
+	}
+}
+
+// watchTree adds the directory and its non-hidden subdirectories to the watcher.
+func watchTree(w *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		return w.Add(path)
+	})
+}
+
+// hidden reports whether the path or one of its parents below dir is hidden, the same files pack skips.
+func hidden(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if strings.HasPrefix(part, ".") && part != "." && part != ".." {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ihippik/lambda-go/lambda"
+	"github.com/ihippik/lambda-go/lambdatest"
+)
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+}
+
+func TestWatchDebounce(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, ".git", "HEAD"), "ref")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := make(chan struct{}, 10)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- watch(ctx, dir, func() { calls <- struct{}{} })
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+
+		if err := <-done; err != nil {
+			t.Errorf("watch: %v", err)
+		}
+	})
+
+	// the watcher is added to the tree asynchronously.
+	time.Sleep(100 * time.Millisecond)
+
+	// editor saving several files in a row triggers the single rebuild.
+	for _, name := range []string{"main.go", "go.mod", "pkg/util.go"} {
+		writeFile(t, filepath.Join(dir, name), name)
+		time.Sleep(devDebounce / 6)
+	}
+
+	select {
+	case <-calls:
+	case <-time.After(5 * devDebounce):
+		t.Fatal("rebuild is not triggered")
+	}
+
+	select {
+	case <-calls:
+		t.Fatal("changes are not debounced")
+	case <-time.After(2 * devDebounce):
+	}
+
+	// hidden files are not packed, so they do not trigger rebuild.
+	writeFile(t, filepath.Join(dir, ".main.go.swp"), "swap")
+	writeFile(t, filepath.Join(dir, ".git", "HEAD"), "ref2")
+
+	select {
+	case <-calls:
+		t.Fatal("rebuild is triggered by hidden file")
+	case <-time.After(2 * devDebounce):
+	}
+
+	// the directory created after the start is watched as well.
+	writeFile(t, filepath.Join(dir, "internal", "api", "api.go"), "package api")
+	time.Sleep(100 * time.Millisecond)
+	writeFile(t, filepath.Join(dir, "internal", "api", "api.go"), "package api\n")
+
+	select {
+	case <-calls:
+	case <-time.After(5 * devDebounce):
+		t.Fatal("rebuild is not triggered by new directory")
+	}
+}
+
+func TestDeploy(t *testing.T) {
+	const key = lambda.DefaultNamespace + "/hello"
+
+	rt := lambdatest.NewRuntime()
+	rt.Handle(key, func(context.Context, []byte) ([]byte, error) {
+		return []byte("v1"), nil
+	})
+
+	h := lambdatest.New(t, rt, nil)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n")
+
+	// the first deploy creates the function.
+	deploy(ctx, h.Service, key, dir)
+
+	if resp, err := h.Service.Invoke(ctx, key, nil); err != nil || string(resp) != "v1" {
+		t.Fatalf("invoke created function: %q %v", resp, err)
+	}
+
+	// the rebuild swaps the running function.
+	rt.Handle(key, func(context.Context, []byte) ([]byte, error) {
+		return []byte("v2"), nil
+	})
+
+	deploy(ctx, h.Service, key, dir)
+
+	if resp, err := h.Service.Invoke(ctx, key, nil); err != nil || string(resp) != "v2" {
+		t.Fatalf("invoke rebuilt function: %q %v", resp, err)
+	}
+
+	// the running function keeps serving if the new version could not be built.
+	deploy(ctx, h.Service, key, filepath.Join(dir, "missing"))
+
+	if resp, err := h.Service.Invoke(ctx, key, nil); err != nil || string(resp) != "v2" {
+		t.Fatalf("invoke after failed build: %q %v", resp, err)
+	}
+
+	if builds := rt.Builds(); len(builds) != 2 {
+		t.Fatalf("unexpected builds %v", builds)
+	}
+}
@@ -0,0 +1,120 @@
+// Command lambdactl is the command-line client of the lambda-go HTTP API.
+// Its dev command runs the local server which rebuilds the function on source changes.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+)
+
+// command is the lambdactl subcommand.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"init", "init [dir] [-name name]", "scaffold main.go and go.mod of the new function", runInit},
+	{"deploy", "deploy <dir> [-name name] [-replace] [-logs]", "package, upload and build the function", runDeploy},
+	{"invoke", "invoke <name> [-data json | -file path] [-stream]", "invoke the function", runInvoke},
+	{"list", "list", "list functions of the namespace", runList},
+	{"delete", "delete <name>", "delete the function", runDelete},
+	{"logs", "logs <name> [-f] [-since 10m] [-invocation id]", "show function logs", runLogs},
+	{"dev", "dev <dir> [-name name] [-addr 127.0.0.1:9000]", "serve the function locally and rebuild it on change", runDev},
+	{"versions", "versions <name>", "list function versions (not supported by the server)", unsupported("versions")},
+	{"alias", "alias <name> <alias> <version>", "point alias to version (not supported by the server)", unsupported("aliases")},
+	{"config", "config set <name> <key>=<value>", "set function config (not supported by the server)", unsupported("config")},
+}
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "help" {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != os.Args[1] {
+			continue
+		}
+
+		if err := cmd.run(ctx, os.Args[2:]); err != nil {
+			if !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintln(os.Stderr, "lambdactl:", err)
+			}
+
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "lambdactl: unknown command %q\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: lambdactl <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-52s %s\n", cmd.usage, cmd.summary)
+	}
+
+	fmt.Fprintf(
+		os.Stderr,
+		"\nCommon flags: -server (%s), -api-key (%s), -n namespace (%s)\n",
+		envServer, envAPIKey, envNamespace,
+	)
+}
+
+// newFlagSet returns flags of the command with the common flags of the API client.
+func newFlagSet(name string) (*flag.FlagSet, *client) {
+	fs := flag.NewFlagSet("lambdactl "+name, flag.ContinueOnError)
+	c := &client{http: http.DefaultClient}
+
+	c.bindFlags(fs)
+
+	return fs, c
+}
+
+// parseFlags parses flags placed before and after positional arguments and returns from min to max positional ones.
+func parseFlags(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		if fs.NArg() == 0 {
+			break
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(positional) < min || len(positional) > max {
+		return nil, fmt.Errorf("%s: invalid number of arguments, see lambdactl help", fs.Name())
+	}
+
+	return positional, nil
+}
+
+// unsupported returns the command of the feature lambda-go server does not have.
+func unsupported(feature string) func(context.Context, []string) error {
+	return func(context.Context, []string) error {
+		return fmt.Errorf("function %s are not supported by lambda-go server, deploy with -replace to update the function", feature)
+	}
+}
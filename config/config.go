@@ -3,6 +3,7 @@ package config
 import (
 	"context"
 	"fmt"
+	"time"
 
 	cfg "github.com/ihippik/config"
 	"github.com/sethvargo/go-envconfig"
@@ -10,13 +11,109 @@ import (
 
 // Config is a configuration for the service.
 type Config struct {
-	Log *cfg.Logger `env:",prefix=LOG_,required"`
-	App AppCfg      `env:",prefix=APP_,required"`
+	Log      *cfg.Logger `env:",prefix=LOG_,required"`
+	App      AppCfg      `env:",prefix=APP_,required"`
+	Trace    TraceCfg    `env:",prefix=TRACE_"`
+	Auth     AuthCfg     `env:",prefix=AUTH_"`
+	TLS      TLSCfg      `env:",prefix=TLS_"`
+	Security SecurityCfg `env:",prefix=SECURITY_"`
 }
 
 // AppCfg is a configuration for the application.
 type AppCfg struct {
-	ServerAddr string `env:"SERVER_ADDR,required"`
+	ServerAddr       string        `env:"SERVER_ADDR,required"`
+	BatchParallelism int           `env:"BATCH_PARALLELISM,default=4"`
+	IdempotencyTTL   time.Duration `env:"IDEMPOTENCY_TTL,default=24h"`
+	LogBufferSize    int           `env:"LOG_BUFFER_SIZE,default=1000"`
+	MaxConcurrency   int           `env:"MAX_CONCURRENCY,default=0"`
+
+	// GRPCAddr is the listen address of the gRPC management API, it is disabled if empty.
+	GRPCAddr string `env:"GRPC_ADDR"`
+
+	HistorySize         int  `env:"HISTORY_SIZE,default=100"`
+	HistoryPayloads     bool `env:"HISTORY_PAYLOADS,default=false"`
+	HistoryPayloadLimit int  `env:"HISTORY_PAYLOAD_LIMIT,default=65536"`
+
+	FunctionMemoryMB   int `env:"FUNCTION_MEMORY_MB,default=128"`
+	UsageRetentionDays int `env:"USAGE_RETENTION_DAYS,default=31"`
+	// QuotasFile is the JSON file function and namespace quotas are persisted to.
+	QuotasFile string `env:"QUOTAS_FILE,default=quotas.json"`
+
+	// Runtime is the function backend: "docker" or "process" for local child processes built with `go build`.
+	Runtime    string `env:"RUNTIME,default=docker"`
+	RuntimeDir string `env:"RUNTIME_DIR,default=runtime"`
+	// BuildDir is the directory with the Dockerfile of function images,
+	// every archive is unpacked with its copy into the own temporary directory.
+	BuildDir string `env:"BUILD_DIR,default=infra"`
+
+	// NetworkMode is "bridge" for the private Docker network or "host-port" for publishing container ports.
+	NetworkMode string `env:"NETWORK_MODE,default=bridge"`
+	NetworkName string `env:"NETWORK_NAME,default=lambda-go"`
+	// NetworkDNS dials containers by name instead of IP, the control plane must be attached to the network.
+	NetworkDNS bool `env:"NETWORK_DNS,default=false"`
+	// FunctionHostIP is the host address function container ports are published on in host-port mode.
+	FunctionHostIP string `env:"FUNCTION_HOST_IP,default=127.0.0.1"`
+	PortRangeMin   int    `env:"PORT_RANGE_MIN,default=20000"`
+	PortRangeMax   int    `env:"PORT_RANGE_MAX,default=29999"`
+
+	// EgressProxyAddr is the listen address of the egress filtering proxy, egress allow-lists are disabled if empty.
+	EgressProxyAddr string `env:"EGRESS_PROXY_ADDR"`
+	// EgressProxyURL is the proxy URL as seen from function containers, internal network gateway is used if empty.
+	EgressProxyURL string `env:"EGRESS_PROXY_URL"`
+
+	AuditFile string `env:"AUDIT_FILE,default=audit.jsonl"`
+	// RoutesFile is the JSON file custom routes are persisted to.
+	RoutesFile string `env:"ROUTES_FILE,default=routes.json"`
+}
+
+// TraceCfg is a configuration for OpenTelemetry tracing.
+type TraceCfg struct {
+	// Exporter is one of: none, stdout, file, otlp.
+	Exporter     string  `env:"EXPORTER,default=none"`
+	File         string  `env:"FILE,default=traces.json"`
+	OTLPEndpoint string  `env:"OTLP_ENDPOINT,default=localhost:4318"`
+	OTLPInsecure bool    `env:"OTLP_INSECURE,default=true"`
+	ServiceName  string  `env:"SERVICE_NAME,default=lambda-go"`
+	SampleRatio  float64 `env:"SAMPLE_RATIO,default=1"`
+}
+
+// AuthCfg is a configuration for the API authentication.
+type AuthCfg struct {
+	Enabled  bool   `env:"ENABLED,default=false"`
+	KeysFile string `env:"KEYS_FILE,default=api_keys.json"`
+	// BootstrapKey is an admin key accepted as is, it is used to mint the first keys.
+	BootstrapKey string `env:"BOOTSTRAP_KEY"`
+
+	// JWKS is a file path or URL of the identity provider keys, bearer tokens are accepted if it is set.
+	JWKS               string        `env:"JWKS"`
+	JWKSRefresh        time.Duration `env:"JWKS_REFRESH,default=1h"`
+	JWTIssuer          string        `env:"JWT_ISSUER"`
+	JWTAudience        string        `env:"JWT_AUDIENCE"`
+	JWTScopesClaim     string        `env:"JWT_SCOPES_CLAIM,default=scope"`
+	JWTNamespacesClaim string        `env:"JWT_NAMESPACES_CLAIM,default=namespaces"`
+}
+
+// TLSCfg is a configuration for TLS of the API and mTLS between control plane and functions.
+type TLSCfg struct {
+	// CertFile and KeyFile enable HTTPS for the API.
+	CertFile string `env:"CERT_FILE"`
+	KeyFile  string `env:"KEY_FILE"`
+
+	// FunctionMTLS enables internal CA which issues certificates for the function containers.
+	FunctionMTLS bool          `env:"FUNCTION_MTLS,default=false"`
+	CADir        string        `env:"CA_DIR,default=ca"`
+	CertValidity time.Duration `env:"CERT_VALIDITY,default=24h"`
+}
+
+// SecurityCfg is a configuration for the hardening profile of the function containers.
+type SecurityCfg struct {
+	// Enabled applies non-root user, read-only root filesystem, dropped capabilities and no-new-privileges.
+	// It is off by default, so functions writing outside /tmp or requiring root keep working after upgrade.
+	Enabled     bool   `env:"HARDENING,default=false"`
+	User        string `env:"USER,default=65534:65534"`
+	TmpfsSizeMB int    `env:"TMPFS_SIZE_MB,default=64"`
+	// SeccompProfile is a path to the seccomp profile JSON, Docker default profile is used if empty.
+	SeccompProfile string `env:"SECCOMP_PROFILE"`
 }
 
 // NewConfig returns new Config.
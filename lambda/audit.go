@@ -0,0 +1,281 @@
+package lambda
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Audited control-plane actions.
+const (
+	AuditFunctionCreate       = "function.create"
+	AuditFunctionUpdate       = "function.update"
+	AuditFunctionDelete       = "function.delete"
+	AuditSecurityOptOut       = "function.security_opt_out"
+	AuditRouteCreate          = "route.create"
+	AuditRouteUpdate          = "route.update"
+	AuditRouteDelete          = "route.delete"
+	AuditQuotaSet             = "quota.set"
+	AuditQuotaDelete          = "quota.delete"
+	AuditNamespaceQuotaSet    = "namespace_quota.set"
+	AuditNamespaceQuotaDelete = "namespace_quota.delete"
+	AuditKeyMint              = "key.mint"
+	AuditKeyRevoke            = "key.revoke"
+)
+
+// Audit outcomes.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+const auditDefaultLimit = 100
+
+// AuditEntry is a record of the control-plane action.
+type AuditEntry struct {
+	ID         string          `json:"id"`
+	Time       time.Time       `json:"time"`
+	Actor      string          `json:"actor"`
+	RemoteAddr string          `json:"remote_addr,omitempty"`
+	Action     string          `json:"action"`
+	Target     string          `json:"target"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Outcome    string          `json:"outcome"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// auditFilter selects audit entries, empty fields match everything.
+type auditFilter struct {
+	actor  string
+	action string
+	target string
+	from   time.Time
+	to     time.Time
+	limit  int
+}
+
+func (f auditFilter) match(entry *AuditEntry) bool {
+	switch {
+	case f.actor != "" && entry.Actor != f.actor:
+		return false
+	case f.action != "" && entry.Action != f.action:
+		return false
+	case f.target != "" && entry.Target != f.target:
+		return false
+	case !f.from.IsZero() && entry.Time.Before(f.from):
+		return false
+	case !f.to.IsZero() && !entry.Time.Before(f.to):
+		return false
+	default:
+		return true
+	}
+}
+
+// auditLog is an append-only JSON lines file of the audit entries.
+type auditLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func newAuditLog(path string) (*auditLog, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+
+	return &auditLog{path: path, file: file}, nil
+}
+
+// append writes the entry and syncs the file, so the entry survives the crash.
+func (a *auditLog) append(entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal entry: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, err := a.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write entry: %w", err)
+	}
+
+	return a.file.Sync()
+}
+
+// query returns the last entries matching the filter, newest first.
+func (a *auditLog) query(f auditFilter) ([]AuditEntry, error) {
+	file, err := os.Open(a.path)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var matched []AuditEntry
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		var entry AuditEntry
+
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("unmarshal entry: %w", err)
+		}
+
+		if !f.match(&entry) {
+			continue
+		}
+
+		matched = append(matched, entry)
+
+		if len(matched) > f.limit {
+			matched = matched[1:]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read audit log: %w", err)
+	}
+
+	result := make([]AuditEntry, 0, len(matched))
+	for i := len(matched) - 1; i >= 0; i-- {
+		result = append(result, matched[i])
+	}
+
+	return result, nil
+}
+
+// actor returns the subject of the authenticated caller.
+func actor(ctx context.Context) string {
+	if p, ok := PrincipalFromContext(ctx); ok {
+		return p.Subject
+	}
+
+	return "anonymous"
+}
+
+// auditValue marshals the state of the target, nil means the target is absent.
+func auditValue(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return nil
+	}
+
+	return data
+}
+
+// optional returns pointer to the value if it is present.
+func optional[T any](v T, ok bool) *T {
+	if !ok {
+		return nil
+	}
+
+	return &v
+}
+
+// writeAudit records the action of the request caller.
+func (e *Endpoint) writeAudit(r *http.Request, action, target string, before, after any, err error) {
+	e.recordAudit(r.Context(), r.RemoteAddr, action, target, before, after, err)
+}
+
+// recordAudit records the action of the caller authenticated in the context.
+// Failure of the audit log is reported to the service log and counted in audit_failures_total,
+// the action is already done.
+func (e *Endpoint) recordAudit(ctx context.Context, remoteAddr, action, target string, before, after any, err error) {
+	entry := AuditEntry{
+		ID:         newID(),
+		Time:       time.Now().UTC(),
+		Actor:      actor(ctx),
+		RemoteAddr: remoteAddr,
+		Action:     action,
+		Target:     target,
+		Before:     auditValue(before),
+		After:      auditValue(after),
+		Outcome:    AuditSuccess,
+	}
+
+	if err != nil {
+		entry.Outcome = AuditFailure
+		entry.Error = err.Error()
+	}
+
+	if err := e.audit.append(entry); err != nil {
+		auditFailuresTotal.WithLabelValues(action).Inc()
+		e.logger.Error("audit: append error", slog.String("action", action), "err", err.Error())
+	}
+}
+
+// functionInfo returns the registered function or nil.
+func (e *Endpoint) functionInfo(name string) *FunctionInfo {
+	namespace, short := splitName(name)
+
+	for _, info := range e.svc.List(namespace) {
+		if info.Name == short {
+			return &info
+		}
+	}
+
+	return nil
+}
+
+// auditEntries http endpoint for query the audit log.
+// Entries are filtered by actor, action, target and time range [from, to) in RFC 3339.
+func (e *Endpoint) auditEntries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	f := auditFilter{
+		actor:  query.Get("actor"),
+		action: query.Get("action"),
+		target: query.Get("target"),
+		limit:  auditDefaultLimit,
+	}
+
+	for param, dst := range map[string]*time.Time{"from": &f.from, "to": &f.to} {
+		v := query.Get(param)
+		if v == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid "+param, http.StatusBadRequest)
+			return
+		}
+
+		*dst = t
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+
+		f.limit = limit
+	}
+
+	entries, err := e.audit.query(f)
+	if err != nil {
+		e.logger.Error("audit: query error", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	e.writeJSON(w, http.StatusOK, entries)
+}
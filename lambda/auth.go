@@ -0,0 +1,449 @@
+package lambda
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ihippik/lambda-go/config"
+)
+
+// API key scopes.
+const (
+	ScopeFunctionCreate = "function:create"
+	ScopeFunctionInvoke = "function:invoke"
+	ScopeFunctionDelete = "function:delete"
+	ScopeAdmin          = "admin"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	apiKeyPrefix = "lgk_"
+)
+
+var (
+	errUnauthenticated = errors.New("authentication required")
+	errInvalidAPIKey   = errors.New("invalid API key")
+	errForbidden       = errors.New("permission denied")
+)
+
+var knownScopes = []string{ScopeFunctionCreate, ScopeFunctionInvoke, ScopeFunctionDelete, ScopeAdmin}
+
+// Principal is an authenticated caller with its permissions.
+// Empty Namespaces and Functions mean no restriction.
+type Principal struct {
+	Subject    string         `json:"subject"`
+	Scopes     []string       `json:"scopes"`
+	Namespaces []string       `json:"namespaces,omitempty"`
+	Functions  []string       `json:"functions,omitempty"`
+	Claims     map[string]any `json:"claims,omitempty"`
+}
+
+// allows reports whether the principal has the scope for the function.
+// Function is a registry key, empty function means the action is not bound to a function
+// and key with empty name ("namespace/") means the action on the whole namespace.
+func (p *Principal) allows(scope, function string) bool {
+	if slices.Contains(p.Scopes, ScopeAdmin) {
+		return true
+	}
+
+	if !slices.Contains(p.Scopes, scope) {
+		return false
+	}
+
+	if function == "" {
+		return true
+	}
+
+	namespace, name := splitName(function)
+
+	if len(p.Namespaces) > 0 && !slices.Contains(p.Namespaces, namespace) {
+		return false
+	}
+
+	if len(p.Functions) == 0 {
+		return true
+	}
+
+	return name != "" && slices.Contains(p.Functions, function)
+}
+
+// withPrincipal returns a copy of the context with authenticated principal.
+func withPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFromContext returns authenticated principal of the request.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey).(*Principal)
+	return p, ok
+}
+
+// APIKey is a stored API key, only hash of the secret is kept.
+type APIKey struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Hash       string    `json:"hash,omitempty"`
+	Scopes     []string  `json:"scopes"`
+	Namespaces []string  `json:"namespaces,omitempty"`
+	Functions  []string  `json:"functions,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// validate checks scopes and normalizes function references.
+func (k *APIKey) validate() error {
+	if len(k.Scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+
+	for _, scope := range k.Scopes {
+		if !slices.Contains(knownScopes, scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+
+	for _, namespace := range k.Namespaces {
+		if !namespaceRe.MatchString(namespace) {
+			return fmt.Errorf("invalid namespace %q", namespace)
+		}
+	}
+
+	for i, ref := range k.Functions {
+		name, err := parseFunction(ref)
+		if err != nil {
+			return err
+		}
+
+		k.Functions[i] = name
+	}
+
+	return nil
+}
+
+// hashSecret returns hex-encoded SHA-256 of the key secret.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// keyStore is an API keys storage persisted to the local file.
+type keyStore struct {
+	mu   sync.RWMutex
+	path string
+	keys map[string]APIKey
+}
+
+// newKeyStore loads API keys from the file, missing file means no keys.
+func newKeyStore(path string) (*keyStore, error) {
+	s := &keyStore{path: path, keys: make(map[string]APIKey)}
+
+	var keys []APIKey
+
+	if err := readJSONFile(path, &keys); err != nil {
+		return nil, err
+	}
+
+	for _, k := range keys {
+		s.keys[k.ID] = k
+	}
+
+	return s, nil
+}
+
+// save writes keys to the file atomically. Must be called with the lock held.
+func (s *keyStore) save() error {
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+
+	return writeJSONFile(s.path, keys)
+}
+
+// mint creates new API key and returns its plaintext value which is not stored.
+func (s *keyStore) mint(k APIKey) (APIKey, string, error) {
+	k.ID = newID()[:16]
+	k.CreatedAt = time.Now().UTC()
+
+	secret := newID()
+	k.Hash = hashSecret(secret)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[k.ID] = k
+
+	if err := s.save(); err != nil {
+		delete(s.keys, k.ID)
+		return APIKey{}, "", err
+	}
+
+	return k, apiKeyPrefix + k.ID + "_" + secret, nil
+}
+
+// revoke removes API key and returns it without hash.
+func (s *keyStore) revoke(id string) (APIKey, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return APIKey{}, false, nil
+	}
+
+	delete(s.keys, id)
+
+	if err := s.save(); err != nil {
+		s.keys[id] = k
+		return APIKey{}, false, err
+	}
+
+	k.Hash = ""
+
+	return k, true, nil
+}
+
+// list returns API keys without hashes.
+func (s *keyStore) list() []APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]APIKey, 0, len(s.keys))
+
+	for _, k := range s.keys {
+		k.Hash = ""
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+
+	return keys
+}
+
+// verify returns API key matching the plaintext value.
+func (s *keyStore) verify(value string) (APIKey, bool) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(value, apiKeyPrefix), "_")
+	if !ok {
+		return APIKey{}, false
+	}
+
+	s.mu.RLock()
+	k, ok := s.keys[id]
+	s.mu.RUnlock()
+
+	if !ok || subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hashSecret(secret))) != 1 {
+		return APIKey{}, false
+	}
+
+	return k, true
+}
+
+// authenticator resolves the principal of the request.
+type authenticator struct {
+	keys         *keyStore
+	jwt          *jwtVerifier
+	bootstrapKey string
+}
+
+// newAuthenticator returns authenticator or nil if authentication is disabled.
+func newAuthenticator(ctx context.Context, cfg config.AuthCfg) (*authenticator, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	keys, err := newKeyStore(cfg.KeysFile)
+	if err != nil {
+		return nil, fmt.Errorf("new key store: %w", err)
+	}
+
+	verifier, err := newJWTVerifier(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("new JWT verifier: %w", err)
+	}
+
+	return &authenticator{keys: keys, jwt: verifier, bootstrapKey: cfg.BootstrapKey}, nil
+}
+
+// authenticate returns the principal of the request credentials, header returns the request header value.
+// Bearer token is either API key or JWT if JWKS is configured.
+func (a *authenticator) authenticate(ctx context.Context, header func(key string) string) (*Principal, error) {
+	value := header(apiKeyHeader)
+	if value == "" {
+		token, ok := strings.CutPrefix(header("Authorization"), "Bearer ")
+
+		switch {
+		case !ok:
+		case strings.HasPrefix(token, apiKeyPrefix):
+			value = token
+		case a.jwt != nil:
+			return a.jwt.verify(ctx, token)
+		}
+	}
+
+	if value == "" {
+		return nil, errUnauthenticated
+	}
+
+	if a.bootstrapKey != "" && subtle.ConstantTimeCompare([]byte(value), []byte(a.bootstrapKey)) == 1 {
+		return &Principal{Subject: "bootstrap", Scopes: []string{ScopeAdmin}}, nil
+	}
+
+	k, ok := a.keys.verify(value)
+	if !ok {
+		return nil, errInvalidAPIKey
+	}
+
+	return &Principal{
+		Subject:    "key:" + k.ID,
+		Scopes:     k.Scopes,
+		Namespaces: k.Namespaces,
+		Functions:  k.Functions,
+	}, nil
+}
+
+// authorize returns handler which lets the request through only if the caller has the scope for the function.
+// target returns function registry key of the request, nil target means the action is not bound to a function.
+func (e *Endpoint) authorize(scope string, target func(r *http.Request) string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if e.auth == nil {
+			next(w, r)
+			return
+		}
+
+		p, err := e.auth.authenticate(r.Context(), r.Header.Get)
+		if err != nil {
+			e.logger.Warn("auth: unauthenticated request", slog.String("path", r.URL.Path), "err", err.Error())
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+
+			return
+		}
+
+		var function string
+		if target != nil {
+			function = target(r)
+		}
+
+		if !p.allows(scope, function) {
+			e.logger.Warn(
+				"auth: permission denied",
+				slog.String("subject", p.Subject),
+				slog.String("scope", scope),
+				slog.String("func_name", function),
+			)
+			http.Error(w, errForbidden.Error(), http.StatusForbidden)
+
+			return
+		}
+
+		next(w, r.WithContext(withPrincipal(r.Context(), p)))
+	}
+}
+
+// namespaceTarget returns the key of the whole namespace addressed by the request.
+func namespaceTarget(r *http.Request) string {
+	return qualify(requestNamespace(r), "")
+}
+
+// admin returns handler available only for callers with admin scope.
+func (e *Endpoint) admin(next http.HandlerFunc) http.HandlerFunc {
+	return e.authorize(ScopeAdmin, nil, next)
+}
+
+// checkAccess reports whether the caller of the request has the scope for the function.
+// It is used by handlers which know the function only after the request was parsed.
+func (e *Endpoint) checkAccess(r *http.Request, scope, function string) bool {
+	return e.allowed(r.Context(), scope, function)
+}
+
+// allowed reports whether the caller authenticated in the context has the scope for the function.
+func (e *Endpoint) allowed(ctx context.Context, scope, function string) bool {
+	if e.auth == nil {
+		return true
+	}
+
+	p, ok := PrincipalFromContext(ctx)
+
+	return ok && p.allows(scope, function)
+}
+
+type mintKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// mintKey http endpoint for create new API key. The key value is returned only once.
+func (e *Endpoint) mintKey(w http.ResponseWriter, r *http.Request) {
+	var k APIKey
+
+	if err := json.NewDecoder(r.Body).Decode(&k); err != nil {
+		e.writeAudit(r, AuditKeyMint, "", nil, nil, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := k.validate(); err != nil {
+		e.writeAudit(r, AuditKeyMint, "", nil, nil, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	k, value, err := e.auth.keys.mint(k)
+	if err != nil {
+		e.writeAudit(r, AuditKeyMint, "", nil, nil, err)
+		e.logger.Error("keys: mint error", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	e.logger.Info("keys: key minted", slog.String("id", k.ID), slog.Any("scopes", k.Scopes))
+
+	k.Hash = ""
+	e.writeAudit(r, AuditKeyMint, k.ID, nil, k, nil)
+
+	e.writeJSON(w, http.StatusCreated, mintKeyResponse{APIKey: k, Key: value})
+}
+
+// listKeys http endpoint for list API keys.
+func (e *Endpoint) listKeys(w http.ResponseWriter, _ *http.Request) {
+	e.writeJSON(w, http.StatusOK, e.auth.keys.list())
+}
+
+// revokeKey http endpoint for revoke API key.
+func (e *Endpoint) revokeKey(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	before, ok, err := e.auth.keys.revoke(id)
+	if err != nil {
+		e.writeAudit(r, AuditKeyRevoke, id, nil, nil, err)
+		e.logger.Error("keys: revoke error", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if !ok {
+		http.Error(w, "key not found", http.StatusNotFound)
+		return
+	}
+
+	e.writeAudit(r, AuditKeyRevoke, id, before, nil, nil)
+	e.logger.Info("keys: key revoked", slog.String("id", id))
+
+	w.WriteHeader(http.StatusNoContent)
+}
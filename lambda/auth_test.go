@@ -0,0 +1,226 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrincipalAllows(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal Principal
+		scope     string
+		function  string
+		want      bool
+	}{
+		{
+			name:      "admin scope",
+			principal: Principal{Scopes: []string{ScopeAdmin}, Namespaces: []string{"team-a"}},
+			scope:     ScopeFunctionDelete,
+			function:  "team-b/hello",
+			want:      true,
+		},
+		{
+			name:      "missing scope",
+			principal: Principal{Scopes: []string{ScopeFunctionInvoke}},
+			scope:     ScopeFunctionCreate,
+			function:  "default/hello",
+		},
+		{
+			name:      "unrestricted key",
+			principal: Principal{Scopes: []string{ScopeFunctionInvoke}},
+			scope:     ScopeFunctionInvoke,
+			function:  "team-b/hello",
+			want:      true,
+		},
+		{
+			name:      "action without function",
+			principal: Principal{Scopes: []string{ScopeFunctionInvoke}, Functions: []string{"default/hello"}},
+			scope:     ScopeFunctionInvoke,
+			want:      true,
+		},
+		{
+			name:      "namespace-restricted key in its namespace",
+			principal: Principal{Scopes: []string{ScopeFunctionInvoke}, Namespaces: []string{"team-a"}},
+			scope:     ScopeFunctionInvoke,
+			function:  "team-a/hello",
+			want:      true,
+		},
+		{
+			name:      "namespace-restricted key in other namespace",
+			principal: Principal{Scopes: []string{ScopeFunctionInvoke}, Namespaces: []string{"team-a"}},
+			scope:     ScopeFunctionInvoke,
+			function:  "team-b/hello",
+		},
+		{
+			name:      "namespace-restricted key in default namespace",
+			principal: Principal{Scopes: []string{ScopeFunctionInvoke}, Namespaces: []string{"team-a"}},
+			scope:     ScopeFunctionInvoke,
+			function:  "hello",
+		},
+		{
+			name:      "namespace-restricted key on its namespace",
+			principal: Principal{Scopes: []string{ScopeFunctionInvoke}, Namespaces: []string{"team-a"}},
+			scope:     ScopeFunctionInvoke,
+			function:  qualify("team-a", ""),
+			want:      true,
+		},
+		{
+			name:      "namespace-restricted key on other namespace",
+			principal: Principal{Scopes: []string{ScopeFunctionInvoke}, Namespaces: []string{"team-a"}},
+			scope:     ScopeFunctionInvoke,
+			function:  qualify("team-b", ""),
+		},
+		{
+			name:      "function-restricted key on its function",
+			principal: Principal{Scopes: []string{ScopeFunctionInvoke}, Functions: []string{"team-a/hello"}},
+			scope:     ScopeFunctionInvoke,
+			function:  "team-a/hello",
+			want:      true,
+		},
+		{
+			name:      "function-restricted key on other function",
+			principal: Principal{Scopes: []string{ScopeFunctionInvoke}, Functions: []string{"team-a/hello"}},
+			scope:     ScopeFunctionInvoke,
+			function:  "team-a/world",
+		},
+		{
+			name:      "function-restricted key on the same name in other namespace",
+			principal: Principal{Scopes: []string{ScopeFunctionInvoke}, Functions: []string{"team-a/hello"}},
+			scope:     ScopeFunctionInvoke,
+			function:  "team-b/hello",
+		},
+		{
+			name:      "function-restricted key on its namespace",
+			principal: Principal{Scopes: []string{ScopeFunctionInvoke}, Functions: []string{"team-a/hello"}},
+			scope:     ScopeFunctionInvoke,
+			function:  qualify("team-a", ""),
+		},
+		{
+			name: "function outside of restricted namespaces",
+			principal: Principal{
+				Scopes:     []string{ScopeFunctionInvoke},
+				Namespaces: []string{"team-a"},
+				Functions:  []string{"team-b/hello"},
+			},
+			scope:    ScopeFunctionInvoke,
+			function: "team-b/hello",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.principal.allows(tt.scope, tt.function); got != tt.want {
+				t.Fatalf("allows(%q, %q) = %v, want %v", tt.scope, tt.function, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_keys.json")
+
+	store, err := newKeyStore(path)
+	if err != nil {
+		t.Fatalf("new key store: %v", err)
+	}
+
+	key, value, err := store.mint(APIKey{Name: "ci", Scopes: []string{ScopeFunctionInvoke}})
+	if err != nil {
+		t.Fatalf("mint: %v", err)
+	}
+
+	_, secret, _ := strings.Cut(strings.TrimPrefix(value, apiKeyPrefix+key.ID), "_")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read keys file: %v", err)
+	}
+
+	if strings.Contains(string(data), secret) || !strings.Contains(string(data), hashSecret(secret)) {
+		t.Fatalf("key secret is stored instead of its hash: %s", data)
+	}
+
+	// keys are looked up by the hash after reload.
+	if store, err = newKeyStore(path); err != nil {
+		t.Fatalf("reload key store: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "valid key", value: value, want: true},
+		{name: "wrong secret", value: apiKeyPrefix + key.ID + "_" + secret + "x"},
+		{name: "unknown id", value: apiKeyPrefix + "unknown_" + secret},
+		{name: "without separator", value: apiKeyPrefix + key.ID},
+		{name: "hash as secret", value: apiKeyPrefix + key.ID + "_" + hashSecret(secret)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := store.verify(tt.value)
+			if ok != tt.want {
+				t.Fatalf("verify = %v, want %v", ok, tt.want)
+			}
+
+			if ok && got.ID != key.ID {
+				t.Fatalf("unexpected key %v", got)
+			}
+		})
+	}
+
+	if _, ok, err := store.revoke(key.ID); err != nil || !ok {
+		t.Fatalf("revoke: %v %v", ok, err)
+	}
+
+	if _, ok := store.verify(value); ok {
+		t.Fatal("revoked key is valid")
+	}
+}
+
+func TestAuthenticatorAPIKey(t *testing.T) {
+	a := &authenticator{bootstrapKey: "bootstrap-secret"}
+
+	var err error
+
+	if a.keys, err = newKeyStore(filepath.Join(t.TempDir(), "api_keys.json")); err != nil {
+		t.Fatalf("new key store: %v", err)
+	}
+
+	_, value, err := a.keys.mint(APIKey{Scopes: []string{ScopeFunctionInvoke}, Namespaces: []string{"team-a"}})
+	if err != nil {
+		t.Fatalf("mint: %v", err)
+	}
+
+	headers := func(h map[string]string) func(string) string {
+		return func(key string) string { return h[key] }
+	}
+
+	p, err := a.authenticate(context.Background(), headers(map[string]string{"Authorization": "Bearer " + value}))
+	if err != nil {
+		t.Fatalf("authenticate bearer key: %v", err)
+	}
+
+	if !p.allows(ScopeFunctionInvoke, "team-a/hello") || p.allows(ScopeFunctionInvoke, "team-b/hello") {
+		t.Fatalf("unexpected principal %+v", p)
+	}
+
+	p, err = a.authenticate(context.Background(), headers(map[string]string{apiKeyHeader: "bootstrap-secret"}))
+	if err != nil || !p.allows(ScopeAdmin, "") {
+		t.Fatalf("authenticate bootstrap key: %+v %v", p, err)
+	}
+
+	if _, err := a.authenticate(context.Background(), headers(nil)); !errors.Is(err, errUnauthenticated) {
+		t.Fatalf("authenticate without key: %v", err)
+	}
+
+	if _, err := a.authenticate(context.Background(), headers(map[string]string{apiKeyHeader: value + "x"})); !errors.Is(err, errInvalidAPIKey) {
+		t.Fatalf("authenticate invalid key: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// MapError reports the items failed during Map call.
+type MapError struct {
+	// Errors has the same length as items, nil means the item was processed successfully.
+	Errors []error
+	Failed int
+}
+
+func (e *MapError) Error() string {
+	for _, err := range e.Errors {
+		if err != nil {
+			return fmt.Sprintf("%d of %d items failed, first error: %s", e.Failed, len(e.Errors), err)
+		}
+	}
+
+	return "no items failed"
+}
+
+func (e *MapError) Unwrap() []error {
+	errs := make([]error, 0, e.Failed)
+
+	for _, err := range e.Errors {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// Map calls fn for every item concurrently with at most parallelism calls in flight.
+// Results are returned in the same order as items.
+// If some of the items failed, *MapError is returned along with the results of the succeeded items.
+func Map[T, R any](
+	ctx context.Context,
+	items []T,
+	parallelism int,
+	fn func(ctx context.Context, item T) (R, error),
+) ([]R, error) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var (
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, parallelism)
+		results = make([]R, len(items))
+		errs    = make([]error, len(items))
+	)
+
+	for i, item := range items {
+		// select does not prefer canceled context over the free slot.
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(i int, item T) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			results[i], errs[i] = fn(ctx, item)
+		}(i, item)
+	}
+
+	wg.Wait()
+
+	mapErr := &MapError{Errors: errs}
+
+	for _, err := range errs {
+		if err != nil {
+			mapErr.Failed++
+		}
+	}
+
+	if mapErr.Failed > 0 {
+		return results, mapErr
+	}
+
+	return results, nil
+}
+
+// InvokeBatch invokes lambda function for every payload concurrently.
+// Parallelism is capped by configuration, non-positive value means the configured cap.
+func (s *Service) InvokeBatch(ctx context.Context, name string, payloads [][]byte, parallelism int) ([][]byte, error) {
+	limit := s.cfg.App.BatchParallelism
+	if parallelism > 0 && parallelism < limit {
+		limit = parallelism
+	}
+
+	s.log.Info("invoke batch", slog.String("func_name", name), slog.Int("size", len(payloads)), slog.Int("parallelism", limit))
+
+	return Map(ctx, payloads, limit, func(ctx context.Context, data []byte) ([]byte, error) {
+		return s.Invoke(ctx, name, data)
+	})
+}
+
+type batchItem struct {
+	Index  int             `json:"index"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchItem `json:"results"`
+	Failed  int         `json:"failed"`
+}
+
+// invokeBatch http endpoint for invoke lambda function over JSON array of payloads.
+func (e *Endpoint) invokeBatch(w http.ResponseWriter, r *http.Request) {
+	name := funcName(r)
+
+	var items []json.RawMessage
+
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		http.Error(w, "payload must be JSON array: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var parallelism int
+
+	if v := r.URL.Query().Get("parallelism"); v != "" {
+		var err error
+
+		if parallelism, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "invalid parallelism", http.StatusBadRequest)
+			return
+		}
+	}
+
+	e.logger.Info("got lambda batch request", slog.Any("func_name", name), slog.Int("size", len(items)))
+
+	payloads := make([][]byte, len(items))
+	for i, item := range items {
+		payloads[i] = item
+	}
+
+	results, err := e.svc.InvokeBatch(r.Context(), name, payloads, parallelism)
+
+	var mapErr *MapError
+	if err != nil && !errors.As(err, &mapErr) {
+		e.logger.Error("batch: invoke service error", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := batchResponse{Results: make([]batchItem, len(results))}
+
+	for i, data := range results {
+		item := batchItem{Index: i}
+
+		if mapErr != nil && mapErr.Errors[i] != nil {
+			item.Error = mapErr.Errors[i].Error()
+			resp.Failed++
+		} else {
+			item.Result = rawResult(data)
+		}
+
+		resp.Results[i] = item
+	}
+
+	e.writeJSON(w, http.StatusOK, resp)
+}
+
+// rawResult returns function response as is if it is valid JSON or as JSON string otherwise.
+func rawResult(data []byte) json.RawMessage {
+	if json.Valid(data) {
+		return data
+	}
+
+	str, _ := json.Marshal(string(data))
+
+	return str
+}
@@ -0,0 +1,113 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMapOrder(t *testing.T) {
+	items := []int{5, 1, 4, 2, 3}
+
+	// later items finish first, results are still in the order of items.
+	results, err := Map(context.Background(), items, len(items), func(_ context.Context, item int) (string, error) {
+		time.Sleep(time.Duration(item) * time.Millisecond)
+		return strconv.Itoa(item), nil
+	})
+	if err != nil {
+		t.Fatalf("map: %v", err)
+	}
+
+	for i, item := range items {
+		if results[i] != strconv.Itoa(item) {
+			t.Fatalf("unexpected results %v", results)
+		}
+	}
+}
+
+func TestMapParallelism(t *testing.T) {
+	for _, parallelism := range []int{-1, 0, 1, 3} {
+		t.Run(strconv.Itoa(parallelism), func(t *testing.T) {
+			var inFlight, peak atomic.Int32
+
+			_, err := Map(context.Background(), make([]int, 20), parallelism, func(context.Context, int) (int, error) {
+				n := inFlight.Add(1)
+				defer inFlight.Add(-1)
+
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+
+				time.Sleep(time.Millisecond)
+
+				return 0, nil
+			})
+			if err != nil {
+				t.Fatalf("map: %v", err)
+			}
+
+			limit := int32(max(parallelism, 1))
+
+			if got := peak.Load(); got > limit {
+				t.Fatalf("%d calls in flight, parallelism is %d", got, limit)
+			}
+		})
+	}
+}
+
+func TestMapPartialFailure(t *testing.T) {
+	errOdd := errors.New("odd item")
+
+	results, err := Map(context.Background(), []int{1, 2, 3, 4}, 2, func(_ context.Context, item int) (int, error) {
+		if item%2 == 1 {
+			return 0, errOdd
+		}
+
+		return item * 10, nil
+	})
+
+	var mapErr *MapError
+	if !errors.As(err, &mapErr) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if mapErr.Failed != 2 || len(mapErr.Errors) != 4 || !errors.Is(err, errOdd) {
+		t.Fatalf("unexpected map error %+v", mapErr)
+	}
+
+	for i, want := range []int{0, 20, 0, 40} {
+		if results[i] != want || (mapErr.Errors[i] == nil) != (want != 0) {
+			t.Fatalf("item %d: result %d, error %v", i, results[i], mapErr.Errors[i])
+		}
+	}
+}
+
+func TestMapCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+
+	// the first call cancels the context, the rest items are not processed.
+	results, err := Map(ctx, []int{1, 2, 3, 4, 5}, 1, func(_ context.Context, item int) (int, error) {
+		calls.Add(1)
+		cancel()
+
+		return item, nil
+	})
+
+	var mapErr *MapError
+	if !errors.As(err, &mapErr) || mapErr.Failed != 4 || !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if n := calls.Load(); n != 1 || results[0] != 1 || mapErr.Errors[0] != nil {
+		t.Fatalf("fn is called %d times after cancel, results %v", n, results)
+	}
+}
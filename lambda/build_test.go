@@ -0,0 +1,93 @@
+package lambda
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ihippik/lambda-go/config"
+)
+
+// tarGz returns tar.gz archive with the files.
+func tarGz(t *testing.T, files map[string]string) io.ReadCloser {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("write header: %v", err)
+		}
+
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar: %v", err)
+	}
+
+	if err := gz.Close(); err != nil {
+		t.Fatalf("close gzip: %v", err)
+	}
+
+	return io.NopCloser(&buf)
+}
+
+func TestBuildContext(t *testing.T) {
+	buildDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(buildDir, "Dockerfile"), []byte("FROM scratch\n"), 0o644); err != nil {
+		t.Fatalf("write Dockerfile: %v", err)
+	}
+
+	s := &Service{
+		cfg: &config.Config{App: config.AppCfg{BuildDir: buildDir}},
+		log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	first, err := s.buildContext(tarGz(t, map[string]string{"main.go": "package a", "extra.go": "package a"}))
+	if err != nil {
+		t.Fatalf("first build context: %v", err)
+	}
+	defer os.RemoveAll(first)
+
+	second, err := s.buildContext(tarGz(t, map[string]string{"main.go": "package b"}))
+	if err != nil {
+		t.Fatalf("second build context: %v", err)
+	}
+	defer os.RemoveAll(second)
+
+	if first == second {
+		t.Fatal("builds share the directory")
+	}
+
+	if _, err := os.Stat(filepath.Join(second, "extra.go")); !os.IsNotExist(err) {
+		t.Fatalf("file of the previous build is in the build context: %v", err)
+	}
+
+	for _, name := range []string{"Dockerfile", "main.go"} {
+		if _, err := os.Stat(filepath.Join(second, name)); err != nil {
+			t.Fatalf("%s is missing: %v", name, err)
+		}
+	}
+
+	if _, err := s.buildContext(tarGz(t, map[string]string{"src/../../escape.go": "package c"})); err == nil {
+		t.Fatal("archive entry outside the build dir is accepted")
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(buildDir), "escape.go")); !os.IsNotExist(err) {
+		t.Fatal("archive entry is written outside the build dir")
+	}
+}
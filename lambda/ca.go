@@ -0,0 +1,433 @@
+package lambda
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ihippik/lambda-go/builder"
+)
+
+const (
+	// controlPlaneName is the common name of the control plane client certificate.
+	controlPlaneName = "lambda-go-control-plane"
+	// functionServerName is the DNS name of the function gRPC server certificate.
+	functionServerName = "lambda-go-function"
+	// caValidity is the validity period of the internal CA certificate.
+	caValidity = 10 * 365 * 24 * time.Hour
+)
+
+// Files of the function server TLS directory mounted into the container.
+const (
+	tlsCertFile = "tls.crt"
+	tlsKeyFile  = "tls.key"
+	tlsCAFile   = "ca.crt"
+)
+
+// certAuthority is the internal CA which issues certificates for function containers and the control plane.
+type certAuthority struct {
+	dir      string
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certPEM  []byte
+	validity time.Duration
+}
+
+// loadOrCreateCA loads CA certificate and key from the directory, new CA is created if they are absent.
+func loadOrCreateCA(dir string, validity time.Duration) (*certAuthority, error) {
+	// function TLS directories are bind-mounted, so the path must be absolute.
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("CA dir: %w", err)
+	}
+
+	certPath := filepath.Join(dir, "ca.crt")
+	keyPath := filepath.Join(dir, "ca.key")
+
+	certPEM, err := os.ReadFile(certPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return createCA(dir, certPath, keyPath, validity)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read CA certificate: %w", err)
+	}
+
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read CA key: %w", err)
+	}
+
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("parse CA key pair: %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse CA certificate: %w", err)
+	}
+
+	key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("CA key must be ECDSA")
+	}
+
+	return &certAuthority{dir: dir, cert: cert, key: key, certPEM: certPEM, validity: validity}, nil
+}
+
+// createCA generates self-signed CA and stores it in the directory.
+func createCA(dir, certPath, keyPath string, validity time.Duration) (*certAuthority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate CA key: %w", err)
+	}
+
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "lambda-go internal CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("create CA certificate: %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse CA certificate: %w", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create CA dir: %w", err)
+	}
+
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		return nil, fmt.Errorf("write CA key: %w", err)
+	}
+
+	if err := os.WriteFile(certPath, certPEM, 0o644); err != nil {
+		return nil, fmt.Errorf("write CA certificate: %w", err)
+	}
+
+	return &certAuthority{dir: dir, cert: cert, key: key, certPEM: certPEM, validity: validity}, nil
+}
+
+// issue returns PEM-encoded certificate and key signed by CA.
+func (ca *certAuthority) issue(commonName string, dnsNames []string, usage x509.ExtKeyUsage) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate key: %w", err)
+	}
+
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(ca.validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create certificate: %w", err)
+	}
+
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM, nil
+}
+
+// functionDir returns the host directory with TLS files of the function container.
+func (ca *certAuthority) functionDir(container string) string {
+	return filepath.Join(ca.dir, "functions", container)
+}
+
+// writeFunctionCerts issues the function server certificate to the TLS directory of the container
+// and returns its expiry. Files are owned by the container user ("uid:gid"), so non-root function could read them.
+// Files are replaced by rename, the function server reloads them when the certificate file is changed.
+func (ca *certAuthority) writeFunctionCerts(dir, name, user string) (time.Time, error) {
+	certPEM, keyPEM, err := ca.issue(name, []string{functionServerName}, x509.ExtKeyUsageServerAuth)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	expiry := time.Now().Add(ca.validity)
+
+	uid, gid, err := parseOwner(user)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return time.Time{}, fmt.Errorf("create TLS dir: %w", err)
+	}
+
+	// the key is written first, so the changed certificate is never paired with the previous key.
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{tlsCAFile, ca.certPEM},
+		{tlsKeyFile, keyPEM},
+		{tlsCertFile, certPEM},
+	}
+
+	for _, f := range files {
+		if err := writeOwned(filepath.Join(dir, f.name), f.data, uid, gid); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	if uid >= 0 {
+		if err := os.Chown(dir, uid, gid); err != nil {
+			return time.Time{}, fmt.Errorf("chown TLS dir: %w", err)
+		}
+	}
+
+	return expiry, nil
+}
+
+// writeOwned atomically writes the file readable by its owner only, owner is not changed if uid is negative.
+func writeOwned(path string, data []byte, uid, gid int) error {
+	tmp := path + ".tmp"
+
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+
+	if uid >= 0 {
+		if err := os.Chown(tmp, uid, gid); err != nil {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("chown %s: %w", filepath.Base(path), err)
+		}
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+
+	return nil
+}
+
+// parseOwner parses numeric "uid[:gid]" of the container user, -1 is returned for the image user.
+func parseOwner(user string) (int, int, error) {
+	if user == "" {
+		return -1, -1, nil
+	}
+
+	uidStr, gidStr, ok := strings.Cut(user, ":")
+
+	uid, err := strconv.Atoi(uidStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("security user %q must be numeric uid:gid with mTLS", user)
+	}
+
+	gid := uid
+
+	if ok {
+		if gid, err = strconv.Atoi(gidStr); err != nil {
+			return 0, 0, fmt.Errorf("security user %q must be numeric uid:gid with mTLS", user)
+		}
+	}
+
+	return uid, gid, nil
+}
+
+// clientConfig returns TLS config of the control plane for dialing function containers.
+// The client certificate is reissued when half of its validity is passed.
+func (ca *certAuthority) clientConfig() (*tls.Config, error) {
+	client := &clientCert{ca: ca}
+
+	if _, err := client.get(); err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	return &tls.Config{
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return client.get()
+		},
+		RootCAs:    pool,
+		ServerName: functionServerName,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// clientCert is the control plane client certificate renewed before it expires.
+type clientCert struct {
+	ca *certAuthority
+
+	mu    sync.Mutex
+	cert  *tls.Certificate
+	renew time.Time
+}
+
+func (c *clientCert) get() (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cert != nil && time.Now().Before(c.renew) {
+		return c.cert, nil
+	}
+
+	certPEM, keyPEM, err := c.ca.issue(controlPlaneName, nil, x509.ExtKeyUsageClientAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("parse client key pair: %w", err)
+	}
+
+	c.cert = &cert
+	c.renew = time.Now().Add(c.ca.validity / 2)
+
+	return c.cert, nil
+}
+
+// functionTLSConfig returns TLS config of the function gRPC server from the TLS directory or nil if it is not set.
+// Only clients with the control plane certificate issued by the internal CA are accepted.
+func functionTLSConfig() (*tls.Config, error) {
+	dir := os.Getenv(builder.EnvTLSDir)
+	if dir == "" {
+		return nil, nil
+	}
+
+	caPEM, err := os.ReadFile(filepath.Join(dir, tlsCAFile))
+	if err != nil {
+		return nil, fmt.Errorf("read CA certificate: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("invalid CA certificate")
+	}
+
+	server := &serverCert{dir: dir}
+
+	if _, err := server.get(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return server.get()
+		},
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		MinVersion: tls.VersionTLS12,
+		VerifyPeerCertificate: func(_ [][]byte, chains [][]*x509.Certificate) error {
+			if len(chains) == 0 || chains[0][0].Subject.CommonName != controlPlaneName {
+				return errors.New("client is not the control plane")
+			}
+
+			return nil
+		},
+	}, nil
+}
+
+// serverCert is the function server certificate reloaded when the control plane renews its file.
+type serverCert struct {
+	dir string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// get returns the certificate, the previous one is kept if the renewed files could not be loaded.
+func (c *serverCert) get() (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	certPath := filepath.Join(c.dir, tlsCertFile)
+
+	info, err := os.Stat(certPath)
+	if err == nil && c.cert != nil && info.ModTime().Equal(c.modTime) {
+		return c.cert, nil
+	}
+
+	if err == nil {
+		var cert tls.Certificate
+
+		if cert, err = tls.LoadX509KeyPair(certPath, filepath.Join(c.dir, tlsKeyFile)); err == nil {
+			c.cert = &cert
+			c.modTime = info.ModTime()
+
+			return c.cert, nil
+		}
+	}
+
+	if c.cert != nil {
+		return c.cert, nil
+	}
+
+	return nil, fmt.Errorf("load key pair: %w", err)
+}
+
+func serialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial number: %w", err)
+	}
+
+	return serial, nil
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("marshal key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,88 @@
+package lambda
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ihippik/lambda-go/builder"
+)
+
+func TestFunctionCertsRenewal(t *testing.T) {
+	ca, err := loadOrCreateCA(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+
+	dir := ca.functionDir("go-lambda-default.hello")
+
+	if _, err := ca.writeFunctionCerts(dir, "default/hello", ""); err != nil {
+		t.Fatalf("write certs: %v", err)
+	}
+
+	for _, name := range []string{tlsCertFile, tlsKeyFile, tlsCAFile} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("stat %s: %v", name, err)
+		}
+
+		if info.Mode().Perm() != 0o600 {
+			t.Fatalf("%s mode %v", name, info.Mode().Perm())
+		}
+	}
+
+	t.Setenv(builder.EnvTLSDir, dir)
+
+	serverTLS, err := functionTLSConfig()
+	if err != nil {
+		t.Fatalf("server config: %v", err)
+	}
+
+	clientTLS, err := ca.clientConfig()
+	if err != nil {
+		t.Fatalf("client config: %v", err)
+	}
+
+	first := handshake(t, serverTLS, clientTLS)
+
+	// the renewed file must differ in modification time even on coarse file systems.
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := ca.writeFunctionCerts(dir, "default/hello", ""); err != nil {
+		t.Fatalf("renew certs: %v", err)
+	}
+
+	if second := handshake(t, serverTLS, clientTLS); second == first {
+		t.Fatal("renewed certificate is not served")
+	}
+}
+
+// handshake connects the client to the server and returns the serial number of the server certificate.
+func handshake(t *testing.T, serverTLS, clientTLS *tls.Config) string {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	errc := make(chan error, 1)
+
+	go func() {
+		errc <- tls.Server(serverConn, serverTLS).Handshake()
+	}()
+
+	client := tls.Client(clientConn, clientTLS)
+
+	if err := client.Handshake(); err != nil {
+		t.Fatalf("client handshake: %v", err)
+	}
+
+	if err := <-errc; err != nil {
+		t.Fatalf("server handshake: %v", err)
+	}
+
+	return client.ConnectionState().PeerCertificates[0].SerialNumber.String()
+}
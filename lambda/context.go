@@ -0,0 +1,45 @@
+package lambda
+
+import "context"
+
+type ctxKey int
+
+const (
+	invocationIDKey ctxKey = iota
+	replayOfKey
+	principalKey
+	claimsKey
+)
+
+// InvocationID returns the ID of the current invocation from the context.
+func InvocationID(ctx context.Context) string {
+	id, _ := ctx.Value(invocationIDKey).(string)
+	return id
+}
+
+// withInvocationID returns a copy of the context with invocation ID.
+func withInvocationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, invocationIDKey, id)
+}
+
+// ensureInvocationID returns context with invocation ID, the new one is generated if it is absent.
+func ensureInvocationID(ctx context.Context) (context.Context, string) {
+	if id := InvocationID(ctx); id != "" {
+		return ctx, id
+	}
+
+	id := newID()
+
+	return withInvocationID(ctx, id), id
+}
+
+// withReplayOf returns a copy of the context marked as replay of the invocation.
+func withReplayOf(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, replayOfKey, id)
+}
+
+// replayOf returns ID of the replayed invocation from the context.
+func replayOf(ctx context.Context) string {
+	id, _ := ctx.Value(replayOfKey).(string)
+	return id
+}
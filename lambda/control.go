@@ -0,0 +1,485 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/ihippik/lambda-go/lambda/proto"
+)
+
+// invocationIDMetadata is the response header metadata with the invocation ID.
+var invocationIDMetadata = strings.ToLower(invocationIDHeader)
+
+// controlPlane is the gRPC management API, it shares the service, authentication and audit log with the HTTP API.
+type controlPlane struct {
+	proto.UnimplementedControlPlaneServer
+
+	e *Endpoint
+}
+
+// uploadStream is the server side of CreateFunction and UpdateFunction calls.
+type uploadStream interface {
+	SendAndClose(*proto.Function) error
+	Recv() (*proto.UploadFunctionRequest, error)
+	Context() context.Context
+}
+
+// StartGRPCServer starts the gRPC management API on the configured address.
+func (e *Endpoint) StartGRPCServer(ctx context.Context) error {
+	lis, err := net.Listen("tcp", e.grpcAddr)
+	if err != nil {
+		return err
+	}
+
+	return e.ServeGRPC(ctx, lis)
+}
+
+// ServeGRPC serves the gRPC management API on the listener until context is canceled.
+// The API certificate is used if TLS is configured.
+func (e *Endpoint) ServeGRPC(ctx context.Context, lis net.Listener) error {
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(e.authenticateUnary),
+		grpc.StreamInterceptor(e.authenticateStream),
+	}
+
+	tls := e.tlsCert != "" || e.tlsKey != ""
+
+	if tls {
+		creds, err := credentials.NewServerTLSFromFile(e.tlsCert, e.tlsKey)
+		if err != nil {
+			return err
+		}
+
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	srv := grpc.NewServer(opts...)
+	proto.RegisterControlPlaneServer(srv, &controlPlane{e: e})
+
+	go func() {
+		<-ctx.Done()
+		// log tails last until canceled, so the server is not stopped gracefully.
+		srv.Stop()
+	}()
+
+	e.logger.Info("grpc server started", slog.String("addr", lis.Addr().String()), slog.Bool("tls", tls))
+
+	return srv.Serve(lis)
+}
+
+// authenticateUnary authenticates the unary call.
+func (e *Endpoint) authenticateUnary(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	ctx, err := e.authenticateCall(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// authenticateStream authenticates the streaming call.
+func (e *Endpoint) authenticateStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := e.authenticateCall(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &principalStream{ServerStream: ss, ctx: ctx})
+}
+
+// principalStream is the server stream with the authenticated principal in its context.
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *principalStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticateCall returns context with the principal of the call credentials,
+// they are sent in metadata with the same keys as HTTP headers.
+func (e *Endpoint) authenticateCall(ctx context.Context, method string) (context.Context, error) {
+	if e.auth == nil {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	p, err := e.auth.authenticate(ctx, func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+
+		return ""
+	})
+	if err != nil {
+		e.logger.Warn("auth: unauthenticated request", slog.String("method", method), "err", err.Error())
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return withPrincipal(ctx, p), nil
+}
+
+// authorize returns PermissionDenied error if the caller has no scope for the function.
+func (c *controlPlane) authorize(ctx context.Context, scope, function string) error {
+	if c.allowed(ctx, scope, function) {
+		return nil
+	}
+
+	c.e.logger.Warn(
+		"auth: permission denied",
+		slog.String("subject", actor(ctx)),
+		slog.String("scope", scope),
+		slog.String("func_name", function),
+	)
+
+	return status.Error(codes.PermissionDenied, errForbidden.Error())
+}
+
+// allowed reports whether the caller has the scope for the function.
+func (c *controlPlane) allowed(ctx context.Context, scope, function string) bool {
+	return c.e.allowed(ctx, scope, function)
+}
+
+// CreateFunction builds the function from the uploaded archive, existing function is not replaced.
+func (c *controlPlane) CreateFunction(stream proto.ControlPlane_CreateFunctionServer) error {
+	return c.upload(stream, AuditFunctionCreate, c.e.svc.Create)
+}
+
+// UpdateFunction builds the new version of the existing function.
+func (c *controlPlane) UpdateFunction(stream proto.ControlPlane_UpdateFunctionServer) error {
+	return c.upload(stream, AuditFunctionUpdate, c.e.svc.Update)
+}
+
+// upload receives the function spec and archive and builds the function with build.
+func (c *controlPlane) upload(stream uploadStream, action string, build buildFunc) error {
+	ctx := stream.Context()
+
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	spec := req.GetSpec()
+	if spec == nil {
+		return status.Error(codes.InvalidArgument, "function spec must be sent first")
+	}
+
+	name, err := functionKey(spec.GetFunction())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := c.authorize(ctx, ScopeFunctionCreate, name); err != nil {
+		return err
+	}
+
+	opts := CreateOptions{OptOut: spec.GetSecurityOptOut(), NoEgress: spec.GetNoEgress(), Egress: spec.GetEgress()}
+
+	c.e.logger.Info("got upload request", slog.Any("func_name", name), slog.String("action", action))
+
+	after, err := c.e.buildFunction(ctx, peerAddr(ctx), action, name, &uploadReader{stream: stream}, opts, build)
+	if err != nil {
+		return rpcError(err)
+	}
+
+	if after == nil {
+		return status.Errorf(codes.NotFound, "function %s was deleted", name)
+	}
+
+	return stream.SendAndClose(functionProto(*after))
+}
+
+// DeleteFunction deletes the function.
+func (c *controlPlane) DeleteFunction(ctx context.Context, req *proto.DeleteFunctionRequest) (*proto.DeleteFunctionResponse, error) {
+	name, err := functionKey(req.GetFunction())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := c.authorize(ctx, ScopeFunctionDelete, name); err != nil {
+		return nil, err
+	}
+
+	c.e.logger.Info("got delete request", slog.Any("func_name", name))
+
+	before := c.e.functionInfo(name)
+
+	if err := c.e.svc.Delete(ctx, name); err != nil {
+		c.e.recordAudit(ctx, peerAddr(ctx), AuditFunctionDelete, name, before, before, err)
+		c.e.logger.Error("delete: service error", "err", err.Error())
+
+		return nil, rpcError(err)
+	}
+
+	c.e.recordAudit(ctx, peerAddr(ctx), AuditFunctionDelete, name, before, nil, nil)
+
+	return &proto.DeleteFunctionResponse{}, nil
+}
+
+// ListFunctions returns functions of the namespace.
+func (c *controlPlane) ListFunctions(ctx context.Context, req *proto.ListFunctionsRequest) (*proto.ListFunctionsResponse, error) {
+	namespace := req.GetNamespace()
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+
+	if !namespaceRe.MatchString(namespace) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid namespace %q", namespace)
+	}
+
+	if err := c.authorize(ctx, ScopeFunctionInvoke, qualify(namespace, "")); err != nil {
+		return nil, err
+	}
+
+	functions := c.e.svc.List(namespace)
+	resp := &proto.ListFunctionsResponse{Functions: make([]*proto.Function, 0, len(functions))}
+
+	for _, info := range functions {
+		resp.Functions = append(resp.Functions, functionProto(info))
+	}
+
+	return resp, nil
+}
+
+// Invoke invokes the function, invocation ID is returned in the response and header metadata.
+func (c *controlPlane) Invoke(ctx context.Context, req *proto.InvokeRequest) (*proto.InvokeResponse, error) {
+	name, err := functionKey(req.GetFunction())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := c.authorize(ctx, ScopeFunctionInvoke, name); err != nil {
+		return nil, err
+	}
+
+	ctx, invocationID := ensureInvocationID(ctx)
+
+	if err := grpc.SetHeader(ctx, metadata.Pairs(invocationIDMetadata, invocationID)); err != nil {
+		c.e.logger.Warn("invoke: set header", "err", err.Error())
+	}
+
+	c.e.logger.Info("got lambda request", slog.Any("func_name", name), slog.String("invocation_id", invocationID))
+
+	data, err := c.e.svc.Invoke(ctx, name, req.GetData())
+	if err != nil {
+		c.e.logger.Error("lambda: invoke service error", "err", err.Error())
+		return nil, rpcError(err)
+	}
+
+	return &proto.InvokeResponse{Data: data, InvocationId: invocationID}, nil
+}
+
+// InvokeStream invokes the function and sends its response chunks as they are written.
+func (c *controlPlane) InvokeStream(req *proto.InvokeRequest, stream proto.ControlPlane_InvokeStreamServer) error {
+	name, err := functionKey(req.GetFunction())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := c.authorize(stream.Context(), ScopeFunctionInvoke, name); err != nil {
+		return err
+	}
+
+	ctx, invocationID := ensureInvocationID(stream.Context())
+
+	if err := stream.SetHeader(metadata.Pairs(invocationIDMetadata, invocationID)); err != nil {
+		c.e.logger.Warn("stream: set header", "err", err.Error())
+	}
+
+	c.e.logger.Info("got lambda stream request", slog.Any("func_name", name), slog.String("invocation_id", invocationID))
+
+	w := &invokeStreamWriter{stream: stream, invocationID: invocationID}
+
+	if err := c.e.svc.InvokeStream(ctx, name, req.GetData(), w); err != nil {
+		c.e.logger.Error("stream: invoke service error", "err", err.Error())
+		return rpcError(err)
+	}
+
+	return nil
+}
+
+// TailLogs sends captured function logs matching the filter and then new entries until the call is canceled.
+func (c *controlPlane) TailLogs(req *proto.TailLogsRequest, stream proto.ControlPlane_TailLogsServer) error {
+	ctx := stream.Context()
+
+	name, err := functionKey(req.GetFunction())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := c.authorize(ctx, ScopeFunctionInvoke, name); err != nil {
+		return err
+	}
+
+	var since time.Time
+	if req.GetSince() != nil {
+		since = req.GetSince().AsTime()
+	}
+
+	invocation := req.GetInvocationId()
+
+	// subscribe before reading history to not miss entries in between.
+	tail, cancel := c.e.svc.TailLogs(name)
+	defer cancel()
+
+	var last time.Time
+
+	for _, entry := range c.e.svc.Logs(name, since, invocation) {
+		if err := stream.Send(logEntryProto(entry)); err != nil {
+			return err
+		}
+
+		last = entry.Time
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry := <-tail:
+			if !entry.match(since, invocation) || !entry.Time.After(last) {
+				continue
+			}
+
+			if err := stream.Send(logEntryProto(entry)); err != nil {
+				c.e.logger.Warn("logs: send error", "err", err.Error())
+				return err
+			}
+		}
+	}
+}
+
+// uploadReader reads the archive from chunks of the upload stream.
+type uploadReader struct {
+	stream uploadStream
+	buf    []byte
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	for len(u.buf) == 0 {
+		req, err := u.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+
+		if req.GetSpec() != nil {
+			return 0, errors.New("function spec must be sent once")
+		}
+
+		u.buf = req.GetChunk()
+	}
+
+	n := copy(p, u.buf)
+	u.buf = u.buf[n:]
+
+	return n, nil
+}
+
+func (u *uploadReader) Close() error {
+	return nil
+}
+
+// invokeStreamWriter sends every write of the function response as the stream message.
+type invokeStreamWriter struct {
+	stream       proto.ControlPlane_InvokeStreamServer
+	invocationID string
+}
+
+func (w *invokeStreamWriter) Write(p []byte) (int, error) {
+	// the message is marshaled by Send, so p is not retained.
+	if err := w.stream.Send(&proto.InvokeResponse{Data: p, InvocationId: w.invocationID}); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// functionKey returns registry key of the function reference, empty namespace is the default one.
+func functionKey(ref *proto.FunctionRef) (string, error) {
+	namespace := ref.GetNamespace()
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+
+	return parseFunction(qualify(namespace, ref.GetName()))
+}
+
+func functionProto(info FunctionInfo) *proto.Function {
+	return &proto.Function{
+		Namespace:      info.Namespace,
+		Name:           info.Name,
+		ContainerId:    info.ContainerID,
+		Port:           int32(info.Port),
+		Network:        info.Network,
+		SecurityOptOut: info.OptOut,
+		NoEgress:       info.NoEgress,
+		Egress:         info.Egress,
+	}
+}
+
+func logEntryProto(entry LogEntry) *proto.LogEntry {
+	return &proto.LogEntry{
+		Time:         timestamppb.New(entry.Time),
+		InvocationId: entry.InvocationID,
+		Stream:       entry.Stream,
+		Message:      entry.Message,
+	}
+}
+
+// rpcError returns gRPC status of the service error, handler status is passed through as is.
+// Other codes correspond to HTTP statuses of httpStatus.
+func rpcError(err error) error {
+	var fnErr *FunctionError
+	if errors.As(err, &fnErr) {
+		return status.Error(fnErr.Code, fnErr.Message)
+	}
+
+	code := codes.InvalidArgument
+
+	switch {
+	case errors.Is(err, ErrFunctionUnavailable):
+		code = codes.Unavailable
+	case errors.Is(err, ErrThrottled), errors.Is(err, ErrQuotaExceeded):
+		code = codes.ResourceExhausted
+	case errors.Is(err, ErrFunctionNotFound):
+		code = codes.NotFound
+	case errors.Is(err, ErrFunctionExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, errForbidden):
+		code = codes.PermissionDenied
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	}
+
+	return status.Error(code, err.Error())
+}
+
+// peerAddr returns the remote address of the call.
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+
+	return ""
+}
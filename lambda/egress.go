@@ -0,0 +1,356 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const egressDialTimeout = 10 * time.Second
+
+// egressRule is an allowed egress destination: CIDR with any port or host:port.
+type egressRule struct {
+	network *net.IPNet
+	host    string
+	port    string
+}
+
+// parseEgressRule parses "10.0.0.0/8" or "host:port" destination.
+func parseEgressRule(v string) (egressRule, error) {
+	if _, network, err := net.ParseCIDR(v); err == nil {
+		return egressRule{network: network}, nil
+	}
+
+	host, port, err := net.SplitHostPort(v)
+	if err != nil || host == "" {
+		return egressRule{}, fmt.Errorf("invalid egress destination %q: CIDR or host:port expected", v)
+	}
+
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return egressRule{}, fmt.Errorf("invalid egress destination %q: invalid port", v)
+	}
+
+	return egressRule{host: strings.ToLower(host), port: port}, nil
+}
+
+func parseEgressRules(values []string) ([]egressRule, error) {
+	rules := make([]egressRule, 0, len(values))
+
+	for _, v := range values {
+		rule, err := parseEgressRule(v)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// egressPolicy is the allow-list of the function.
+type egressPolicy struct {
+	function string
+	rules    []egressRule
+}
+
+// resolve returns the address to dial if the destination is allowed.
+// Host names matched by CIDR are resolved once, so the checked address is dialed.
+func (p *egressPolicy) resolve(ctx context.Context, host, port string) (string, bool) {
+	host = strings.ToLower(host)
+
+	var cidr bool
+
+	for _, rule := range p.rules {
+		if rule.network != nil {
+			cidr = true
+			continue
+		}
+
+		if rule.host == host && rule.port == port {
+			return net.JoinHostPort(host, port), true
+		}
+	}
+
+	if !cidr {
+		return "", false
+	}
+
+	var ips []net.IP
+
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		resolved, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+		if err != nil {
+			return "", false
+		}
+
+		ips = resolved
+	}
+
+	for _, ip := range ips {
+		for _, rule := range p.rules {
+			if rule.network != nil && rule.network.Contains(ip) {
+				return net.JoinHostPort(ip.String(), port), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// egressTable maps proxy tokens of the functions to their allow-lists.
+type egressTable struct {
+	mu       sync.RWMutex
+	policies map[string]*egressPolicy
+}
+
+func newEgressTable() *egressTable {
+	return &egressTable{policies: make(map[string]*egressPolicy)}
+}
+
+func (t *egressTable) put(token string, p *egressPolicy) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.policies[token] = p
+}
+
+func (t *egressTable) get(token string) (*egressPolicy, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	p, ok := t.policies[token]
+
+	return p, ok
+}
+
+// remove deletes the policy of the function container.
+func (t *egressTable) remove(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.policies, token)
+}
+
+// registerEgress restores the allow-list of the existing container, the proxy token is taken from its environment.
+// The token is returned or empty string if it is not found.
+func (s *Service) registerEgress(name string, destinations, env []string) string {
+	rules, err := parseEgressRules(destinations)
+	if err != nil {
+		s.log.Warn("init: invalid egress allow-list", slog.String("name", name), "err", err.Error())
+		return ""
+	}
+
+	for _, kv := range env {
+		v, ok := strings.CutPrefix(kv, "HTTP_PROXY=")
+		if !ok {
+			continue
+		}
+
+		u, err := url.Parse(v)
+		if err != nil || u.User == nil {
+			break
+		}
+
+		if token, ok := u.User.Password(); ok {
+			s.egress.put(token, &egressPolicy{function: name, rules: rules})
+			return token
+		}
+	}
+
+	s.log.Warn("init: egress proxy token not found", slog.String("name", name))
+
+	return ""
+}
+
+// egressEnv returns container environment which sends the function traffic through the egress proxy.
+func (s *Service) egressEnv(ctx context.Context, token string) ([]string, error) {
+	proxyURL := s.cfg.App.EgressProxyURL
+
+	if proxyURL == "" {
+		gateway, err := s.builder.NetworkGateway(ctx, s.internalNetwork())
+		if err != nil {
+			return nil, fmt.Errorf("network gateway: %w", err)
+		}
+
+		_, port, err := net.SplitHostPort(s.cfg.App.EgressProxyAddr)
+		if err != nil {
+			return nil, fmt.Errorf("parse proxy address: %w", err)
+		}
+
+		proxyURL = "http://" + net.JoinHostPort(gateway, port)
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse proxy url: %w", err)
+	}
+
+	u.User = url.UserPassword("lambda", token)
+
+	return []string{
+		"HTTP_PROXY=" + u.String(),
+		"HTTPS_PROXY=" + u.String(),
+		"http_proxy=" + u.String(),
+		"https_proxy=" + u.String(),
+	}, nil
+}
+
+// StartEgressProxy starts the filtering proxy which is the only way out of the functions with egress allow-list.
+// It accepts CONNECT tunnels and plain HTTP requests authorized with the function proxy token.
+func (s *Service) StartEgressProxy(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.cfg.App.EgressProxyAddr,
+		Handler:           http.HandlerFunc(s.proxyEgress),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := srv.Shutdown(context.WithoutCancel(ctx)); err != nil {
+			s.log.Error("egress proxy shutdown", "err", err.Error())
+		}
+	}()
+
+	s.log.Info("egress proxy started", slog.String("addr", s.cfg.App.EgressProxyAddr))
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// proxyEgress handles the proxy request of the function.
+func (s *Service) proxyEgress(w http.ResponseWriter, r *http.Request) {
+	_, token, _ := proxyAuth(r)
+
+	policy, ok := s.egress.get(token)
+	if !ok {
+		s.log.Warn("egress: unknown proxy token", slog.String("remote_addr", r.RemoteAddr))
+		w.Header().Set("Proxy-Authenticate", `Basic realm="lambda-go"`)
+		http.Error(w, "proxy authentication required", http.StatusProxyAuthRequired)
+
+		return
+	}
+
+	target := r.Host
+	if r.Method != http.MethodConnect {
+		target = r.URL.Host
+	}
+
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host, port = target, "80"
+	}
+
+	addr, allowed := policy.resolve(r.Context(), host, port)
+	if !allowed {
+		egressBlockedTotal.WithLabelValues(policy.function).Inc()
+		s.log.Warn(
+			"egress: blocked",
+			slog.String("func_name", policy.function),
+			slog.String("target", net.JoinHostPort(host, port)),
+		)
+		http.Error(w, "egress destination is not allowed", http.StatusForbidden)
+
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		s.tunnel(w, r, addr)
+		return
+	}
+
+	s.forward(w, r, addr)
+}
+
+// tunnel connects the hijacked client connection with the destination.
+func (s *Service) tunnel(w http.ResponseWriter, r *http.Request, addr string) {
+	dst, err := net.DialTimeout("tcp", addr, egressDialTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		dst.Close()
+		http.Error(w, "hijacking is not supported", http.StatusInternalServerError)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	src, buf, err := hijacker.Hijack()
+	if err != nil {
+		dst.Close()
+		return
+	}
+
+	go func() {
+		defer dst.Close()
+		_, _ = io.Copy(dst, buf)
+	}()
+
+	defer src.Close()
+	_, _ = io.Copy(src, dst)
+}
+
+// forward sends the plain HTTP request to the checked address.
+func (s *Service) forward(w http.ResponseWriter, r *http.Request, addr string) {
+	transport := &http.Transport{
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{Timeout: egressDialTimeout}).DialContext(ctx, network, addr)
+		},
+	}
+	defer transport.CloseIdleConnections()
+
+	req := r.Clone(r.Context())
+	req.RequestURI = ""
+	req.Header.Del("Proxy-Authorization")
+	req.Header.Del("Proxy-Connection")
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for key, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+
+	w.WriteHeader(resp.StatusCode)
+
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// proxyAuth returns credentials of Proxy-Authorization header.
+func proxyAuth(r *http.Request) (string, string, bool) {
+	auth := r.Header.Get("Proxy-Authorization")
+	if auth == "" {
+		return "", "", false
+	}
+
+	// http.Request.BasicAuth parses Authorization header only.
+	req := http.Request{Header: http.Header{"Authorization": {auth}}}
+
+	return req.BasicAuth()
+}
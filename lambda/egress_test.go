@@ -0,0 +1,258 @@
+package lambda
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestEgressPolicyResolve(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		host  string
+		port  string
+		addr  string
+		allow bool
+	}{
+		{
+			name:  "host and port",
+			rules: []string{"api.example.com:443"},
+			host:  "API.example.com",
+			port:  "443",
+			addr:  "api.example.com:443",
+			allow: true,
+		},
+		{
+			name:  "host on other port",
+			rules: []string{"api.example.com:443"},
+			host:  "api.example.com",
+			port:  "80",
+		},
+		{
+			name:  "other host",
+			rules: []string{"api.example.com:443"},
+			host:  "example.com",
+			port:  "443",
+		},
+		{
+			name:  "IP in CIDR",
+			rules: []string{"10.0.0.0/8"},
+			host:  "10.1.2.3",
+			port:  "5432",
+			addr:  "10.1.2.3:5432",
+			allow: true,
+		},
+		{
+			name:  "IP out of CIDR",
+			rules: []string{"10.0.0.0/8"},
+			host:  "192.168.1.1",
+			port:  "5432",
+		},
+		{
+			name:  "IPv6 in CIDR",
+			rules: []string{"fd00::/8"},
+			host:  "fd00::1",
+			port:  "443",
+			addr:  "[fd00::1]:443",
+			allow: true,
+		},
+		{
+			name:  "resolved host in CIDR is dialed by address",
+			rules: []string{"127.0.0.0/8"},
+			host:  "localhost",
+			port:  "8080",
+			addr:  "127.0.0.1:8080",
+			allow: true,
+		},
+		{
+			name:  "unresolved host",
+			rules: []string{"10.0.0.0/8"},
+			host:  "unknown.invalid",
+			port:  "80",
+		},
+		{
+			name: "empty allow-list",
+			host: "10.1.2.3",
+			port: "80",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseEgressRules(tt.rules)
+			if err != nil {
+				t.Fatalf("parse rules: %v", err)
+			}
+
+			p := egressPolicy{function: "default/hello", rules: rules}
+
+			addr, allow := p.resolve(context.Background(), tt.host, tt.port)
+			if allow != tt.allow || addr != tt.addr {
+				t.Fatalf("resolve(%q, %q) = %q %v, want %q %v", tt.host, tt.port, addr, allow, tt.addr, tt.allow)
+			}
+		})
+	}
+}
+
+func TestParseEgressRule(t *testing.T) {
+	for _, v := range []string{"example.com", ":443", "example.com:0", "example.com:http", "10.0.0.0/33"} {
+		if _, err := parseEgressRule(v); err == nil {
+			t.Errorf("rule %q is accepted", v)
+		}
+	}
+}
+
+// egressProxy starts the proxy of the service with the allow-list registered for the token.
+func egressProxy(t *testing.T, token string, destinations ...string) *url.URL {
+	t.Helper()
+
+	rules, err := parseEgressRules(destinations)
+	if err != nil {
+		t.Fatalf("parse rules: %v", err)
+	}
+
+	s := &Service{log: slog.New(slog.NewTextHandler(io.Discard, nil)), egress: newEgressTable()}
+	s.egress.put(token, &egressPolicy{function: "default/hello", rules: rules})
+
+	srv := httptest.NewServer(http.HandlerFunc(s.proxyEgress))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("parse proxy url: %v", err)
+	}
+
+	return u
+}
+
+// proxyClient returns client which sends requests through the proxy with the token, empty token is not sent.
+func proxyClient(proxy *url.URL, token string, tlsConfig *tls.Config) *http.Client {
+	u := *proxy
+	if token != "" {
+		u.User = url.UserPassword("lambda", token)
+	}
+
+	return &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(&u), TLSClientConfig: tlsConfig}}
+}
+
+func TestEgressProxy(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		_, _ = io.WriteString(w, "ok")
+	})
+
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+
+	tlsConfig := secure.Client().Transport.(*http.Transport).TLSClientConfig
+
+	// upstream servers listen on the loopback, so they are allowed by CIDR and by host:port.
+	_, securePort, _ := net.SplitHostPort(secure.Listener.Addr().String())
+
+	tests := []struct {
+		name   string
+		rules  []string
+		token  string
+		target string
+		status int
+	}{
+		{
+			name:   "plain HTTP allowed by CIDR",
+			rules:  []string{"127.0.0.0/8"},
+			token:  "secret",
+			target: plain.URL,
+			status: http.StatusOK,
+		},
+		{
+			name:   "CONNECT allowed by host and port",
+			rules:  []string{"127.0.0.1:" + securePort},
+			token:  "secret",
+			target: secure.URL,
+			status: http.StatusOK,
+		},
+		{
+			name:   "plain HTTP denied",
+			rules:  []string{"10.0.0.0/8"},
+			token:  "secret",
+			target: plain.URL,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "CONNECT denied",
+			rules:  []string{"127.0.0.1:1"},
+			token:  "secret",
+			target: secure.URL,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "missing token",
+			rules:  []string{"127.0.0.0/8"},
+			target: plain.URL,
+			status: http.StatusProxyAuthRequired,
+		},
+		{
+			name:   "invalid token",
+			rules:  []string{"127.0.0.0/8"},
+			token:  "wrong",
+			target: plain.URL,
+			status: http.StatusProxyAuthRequired,
+		},
+		{
+			name:   "CONNECT with invalid token",
+			rules:  []string{"127.0.0.0/8"},
+			token:  "wrong",
+			target: secure.URL,
+			status: http.StatusProxyAuthRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := egressProxy(t, "secret", tt.rules...)
+
+			resp, err := proxyClient(proxy, tt.token, tlsConfig).Get(tt.target)
+
+			// failed CONNECT is reported by the transport as an error with the proxy response status.
+			if tt.status != http.StatusOK && strings.HasPrefix(tt.target, "https://") {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("tunnel is established: %d", resp.StatusCode)
+				}
+
+				if !strings.Contains(err.Error(), http.StatusText(tt.status)) {
+					t.Fatalf("unexpected error %v, want %d", err, tt.status)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tt.status)
+			}
+
+			if body, _ := io.ReadAll(resp.Body); tt.status == http.StatusOK && string(body) != "ok" {
+				t.Fatalf("unexpected body %q", body)
+			}
+		})
+	}
+}
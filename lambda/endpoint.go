@@ -2,31 +2,207 @@ package lambda
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/ihippik/lambda-go/config"
 )
 
 type service interface {
-	Create(ctx context.Context, name string, file io.ReadCloser) error
+	Create(ctx context.Context, name string, file io.ReadCloser, opts CreateOptions) error
+	Update(ctx context.Context, name string, file io.ReadCloser, opts CreateOptions) error
+	Delete(ctx context.Context, name string) error
 	Invoke(ctx context.Context, name string, data []byte) ([]byte, error)
+	InvokeStream(ctx context.Context, name string, data []byte, w io.Writer) error
+	InvokeBatch(ctx context.Context, name string, payloads [][]byte, parallelism int) ([][]byte, error)
+	Logs(name string, since time.Time, invocation string) []LogEntry
+	TailLogs(name string) (<-chan LogEntry, func())
+	Invocations(name string, n int) []Invocation
+	Invocation(id string) (Invocation, bool)
+	Replay(ctx context.Context, id, name string) ([]byte, error)
+	Usage(namespace, name, from, to string) []Usage
+	NamespaceUsage(namespace, from, to string) []Usage
+	Quota(name string) (Quota, bool)
+	SetQuota(name string, q Quota) error
+	DeleteQuota(name string) (bool, error)
+	NamespaceQuota(namespace string) (Quota, bool)
+	SetNamespaceQuota(namespace string, q Quota) error
+	DeleteNamespaceQuota(namespace string) (bool, error)
+	List(namespace string) []FunctionInfo
 }
 
 // Endpoint represent http-service endpoints.
 type Endpoint struct {
-	svc        service
-	serverAddr string
-	logger     *slog.Logger
+	svc         service
+	serverAddr  string
+	grpcAddr    string
+	tlsCert     string
+	tlsKey      string
+	logger      *slog.Logger
+	idempotency *idempotencyStore
+	routes      *routeTable
+	conns       *wsHub
+	audit       *auditLog
+	auth        *authenticator
+	routerMu    sync.Mutex
+	router      atomic.Pointer[mux.Router]
+	handler     http.Handler
 }
 
 // NewEndpoint returns new Endpoint instance.
-func NewEndpoint(svc service, logger *slog.Logger, addr string) *Endpoint {
-	return &Endpoint{svc: svc, logger: logger, serverAddr: addr}
+func NewEndpoint(cfg *config.Config, svc service, logger *slog.Logger) (*Endpoint, error) {
+	auth, err := newAuthenticator(context.Background(), cfg.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("new authenticator: %w", err)
+	}
+
+	if auth == nil {
+		logger.Warn("authentication is disabled, every caller is allowed all actions, set AUTH_ENABLED=true to require API keys")
+	}
+
+	audit, err := newAuditLog(cfg.App.AuditFile)
+	if err != nil {
+		return nil, fmt.Errorf("new audit log: %w", err)
+	}
+
+	routes, err := newRouteTable(cfg.App.RoutesFile)
+	if err != nil {
+		return nil, fmt.Errorf("new route table: %w", err)
+	}
+
+	e := &Endpoint{
+		svc:         svc,
+		logger:      logger,
+		serverAddr:  cfg.App.ServerAddr,
+		grpcAddr:    cfg.App.GRPCAddr,
+		tlsCert:     cfg.TLS.CertFile,
+		tlsKey:      cfg.TLS.KeyFile,
+		idempotency: newIdempotencyStore(cfg.App.IdempotencyTTL),
+		routes:      routes,
+		conns:       newWSHub(),
+		audit:       audit,
+		auth:        auth,
+	}
+	e.handler = traceHTTP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.router.Load().ServeHTTP(w, r)
+	}))
+
+	e.rebuildRouter()
+
+	return e, nil
+}
+
+// ServeHTTP dispatches the request to the current router.
+func (e *Endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.handler.ServeHTTP(w, r)
+}
+
+// rebuildRouter builds a new router with built-in endpoints and custom routes and swaps the current one.
+func (e *Endpoint) rebuildRouter() {
+	e.routerMu.Lock()
+	defer e.routerMu.Unlock()
+
+	r := mux.NewRouter()
+
+	for _, prefix := range []string{
+		"/lambda/{name:" + funcNamePattern + "}",
+		"/ns/{namespace:" + namespacePattern + "}/lambda/{name:" + funcNamePattern + "}",
+	} {
+		r.HandleFunc(prefix+"/create", e.authorize(ScopeFunctionCreate, funcName, e.create)).Methods(http.MethodPost)
+		r.HandleFunc(prefix+"/update", e.authorize(ScopeFunctionCreate, funcName, e.update)).Methods(http.MethodPost)
+		r.HandleFunc(prefix, e.authorize(ScopeFunctionDelete, funcName, e.delete)).Methods(http.MethodDelete)
+		r.HandleFunc(prefix+"/invoke", e.authorize(ScopeFunctionInvoke, funcName, e.invoke)).Methods(http.MethodPost)
+		r.HandleFunc(prefix+"/invoke-stream", e.authorize(ScopeFunctionInvoke, funcName, e.invokeStream)).
+			Methods(http.MethodPost)
+		r.HandleFunc(prefix+"/invoke-batch", e.authorize(ScopeFunctionInvoke, funcName, e.invokeBatch)).
+			Methods(http.MethodPost)
+		r.HandleFunc(prefix+"/ws", e.authorize(ScopeFunctionInvoke, funcName, e.webSocket)).Methods(http.MethodGet)
+		r.HandleFunc(prefix+"/logs", e.authorize(ScopeFunctionInvoke, funcName, e.logs)).Methods(http.MethodGet)
+		r.HandleFunc(prefix+"/invocations", e.authorize(ScopeFunctionInvoke, funcName, e.invocations)).
+			Methods(http.MethodGet)
+
+		r.HandleFunc(prefix+"/quota", e.admin(e.getQuota)).Methods(http.MethodGet)
+		r.HandleFunc(prefix+"/quota", e.admin(e.setQuota)).Methods(http.MethodPut)
+		r.HandleFunc(prefix+"/quota", e.admin(e.deleteQuota)).Methods(http.MethodDelete)
+	}
+
+	nsPrefix := "/ns/{namespace:" + namespacePattern + "}"
+
+	r.HandleFunc("/lambda", e.authorize(ScopeFunctionInvoke, namespaceTarget, e.list)).Methods(http.MethodGet)
+	r.HandleFunc(nsPrefix+"/lambda", e.authorize(ScopeFunctionInvoke, namespaceTarget, e.list)).Methods(http.MethodGet)
+	r.HandleFunc(nsPrefix+"/usage", e.admin(e.namespaceUsage)).Methods(http.MethodGet)
+	r.HandleFunc(nsPrefix+"/quota", e.admin(e.getNamespaceQuota)).Methods(http.MethodGet)
+	r.HandleFunc(nsPrefix+"/quota", e.admin(e.setNamespaceQuota)).Methods(http.MethodPut)
+	r.HandleFunc(nsPrefix+"/quota", e.admin(e.deleteNamespaceQuota)).Methods(http.MethodDelete)
+
+	// replay checks access to the functions itself, they are known only from the invocation record.
+	r.HandleFunc("/invocations/{id}/replay", e.authorize(ScopeFunctionInvoke, nil, e.replay)).Methods(http.MethodPost)
+	r.HandleFunc("/usage", e.admin(e.usage)).Methods(http.MethodGet)
+	r.HandleFunc("/audit", e.admin(e.auditEntries)).Methods(http.MethodGet)
+
+	r.HandleFunc("/connections/{id}", e.authorize(ScopeFunctionInvoke, e.connectionFunction, e.postToConnection)).
+		Methods(http.MethodPost)
+	r.HandleFunc("/connections/{id}", e.authorize(ScopeFunctionInvoke, e.connectionFunction, e.deleteConnection)).
+		Methods(http.MethodDelete)
+
+	r.Handle("/metrics", e.admin(promhttp.Handler().ServeHTTP)).Methods(http.MethodGet)
+
+	r.HandleFunc("/routes", e.admin(e.listRoutes)).Methods(http.MethodGet)
+	r.HandleFunc("/routes", e.admin(e.createRoute)).Methods(http.MethodPost)
+	r.HandleFunc("/routes/{id}", e.admin(e.updateRoute)).Methods(http.MethodPut)
+	r.HandleFunc("/routes/{id}", e.admin(e.deleteRoute)).Methods(http.MethodDelete)
+
+	if e.auth != nil {
+		r.HandleFunc("/keys", e.admin(e.listKeys)).Methods(http.MethodGet)
+		r.HandleFunc("/keys", e.admin(e.mintKey)).Methods(http.MethodPost)
+		r.HandleFunc("/keys/{id}", e.admin(e.revokeKey)).Methods(http.MethodDelete)
+	}
+
+	// custom routes are the public API surface and are not authenticated.
+	preflight := make(map[string][]Route)
+
+	for _, route := range e.routes.list() {
+		r.Handle(route.Path, e.routeHandler(route)).Methods(route.Method)
+
+		if route.CORS != nil {
+			preflight[route.Path] = append(preflight[route.Path], route)
+		}
+	}
+
+	for path, routes := range preflight {
+		r.Handle(path, e.preflightHandler(routes)).Methods(http.MethodOptions)
+	}
+
+	e.router.Store(r)
+}
+
+// writeJSON writes value as JSON response with the status code.
+func (e *Endpoint) writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		e.logger.Error("marshal error", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if _, err := w.Write(data); err != nil {
+		e.logger.Error("write error", "err", err.Error())
+	}
 }
 
 type createResponse struct {
@@ -36,13 +212,26 @@ type createResponse struct {
 
 // create http endpoint for create lambda function.
 func (e *Endpoint) create(w http.ResponseWriter, r *http.Request) {
+	e.upload(w, r, AuditFunctionCreate, e.svc.Create)
+}
+
+// update http endpoint for update lambda function, the new version is swapped with the running one.
+func (e *Endpoint) update(w http.ResponseWriter, r *http.Request) {
+	e.upload(w, r, AuditFunctionUpdate, e.svc.Update)
+}
+
+// buildFunc builds the function from the archive, it is either Create or Update of the service.
+type buildFunc func(ctx context.Context, name string, file io.ReadCloser, opts CreateOptions) error
+
+// upload reads the function archive of create and update requests and builds the function with build.
+func (e *Endpoint) upload(w http.ResponseWriter, r *http.Request, action string, build buildFunc) {
 	const gzHeader = "application/gzip"
-	vars := mux.Vars(r)
-	name := vars["name"]
+	name := funcName(r)
 
 	file, fileHeader, err := r.FormFile("file")
 	if err != nil {
-		fmt.Println(err)
+		e.logger.Error("upload: form file error", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -51,36 +240,125 @@ func (e *Endpoint) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	e.logger.Info("got create request", slog.Any("func_name", name))
+	e.logger.Info("got upload request", slog.Any("func_name", name), slog.String("action", action))
 
-	if err := e.svc.Create(r.Context(), name, file); err != nil {
-		e.logger.Error("create: service error", "err", err.Error())
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	opts := CreateOptions{
+		OptOut:   parseList(r.FormValue("security_opt_out")),
+		NoEgress: r.FormValue("no_egress") == "true",
+		Egress:   parseList(r.FormValue("egress")),
+	}
+
+	if _, err := e.buildFunction(r.Context(), r.RemoteAddr, action, name, file, opts, build); err != nil {
+		http.Error(w, err.Error(), uploadStatus(err))
 		return
 	}
 
-	resp := createResponse{Name: name, Description: "lambda function was created"}
-	data, err := json.Marshal(resp)
+	code, description := http.StatusCreated, "lambda function was created"
+	if action == AuditFunctionUpdate {
+		code, description = http.StatusOK, "lambda function was updated"
+	}
+
+	data, err := json.Marshal(createResponse{Name: name, Description: description})
 	if err != nil {
-		e.logger.Error("create: marshal error", "err", err.Error())
+		e.logger.Error("upload: marshal error", "err", err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(code)
 
 	_, err = w.Write(data)
 	if err != nil {
-		e.logger.Error("create: write error", "err", err.Error())
+		e.logger.Error("upload: write error", "err", err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
 
+// buildFunction builds the function uploaded over HTTP or gRPC by the caller authenticated in the context
+// and records the action to the audit log. Info of the built function is returned.
+func (e *Endpoint) buildFunction(
+	ctx context.Context,
+	remoteAddr, action, name string,
+	file io.ReadCloser,
+	opts CreateOptions,
+	build buildFunc,
+) (*FunctionInfo, error) {
+	before := e.functionInfo(name)
+
+	// weakening the hardening profile is allowed to admins only.
+	if len(opts.OptOut) > 0 && !e.allowed(ctx, ScopeAdmin, name) {
+		e.recordAudit(ctx, remoteAddr, AuditSecurityOptOut, name, nil, opts, errForbidden)
+		return nil, errForbidden
+	}
+
+	if err := build(ctx, name, file, opts); err != nil {
+		e.recordAudit(ctx, remoteAddr, action, name, before, nil, err)
+		e.logger.Error("upload: service error", "err", err.Error())
+
+		return nil, err
+	}
+
+	after := e.functionInfo(name)
+
+	e.recordAudit(ctx, remoteAddr, action, name, before, after, nil)
+
+	if len(opts.OptOut) > 0 {
+		e.recordAudit(ctx, remoteAddr, AuditSecurityOptOut, name, nil, opts, nil)
+	}
+
+	return after, nil
+}
+
+// uploadStatus returns the status code of the failed build: unknown function of update is not found,
+// existing function of create is a conflict.
+func uploadStatus(err error) int {
+	if errors.Is(err, errForbidden) {
+		return http.StatusForbidden
+	}
+
+	if errors.Is(err, ErrFunctionNotFound) {
+		return http.StatusNotFound
+	}
+
+	if errors.Is(err, ErrFunctionExists) {
+		return http.StatusConflict
+	}
+
+	return http.StatusBadRequest
+}
+
+// delete http endpoint for delete lambda function.
+func (e *Endpoint) delete(w http.ResponseWriter, r *http.Request) {
+	name := funcName(r)
+
+	e.logger.Info("got delete request", slog.Any("func_name", name))
+
+	before := e.functionInfo(name)
+	err := e.svc.Delete(r.Context(), name)
+
+	if err != nil {
+		e.writeAudit(r, AuditFunctionDelete, name, before, before, err)
+		e.logger.Error("delete: service error", "err", err.Error())
+
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrFunctionNotFound) {
+			status = http.StatusNotFound
+		}
+
+		http.Error(w, err.Error(), status)
+
+		return
+	}
+
+	e.writeAudit(r, AuditFunctionDelete, name, before, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // invoke http endpoint for invoke lambda function.
 func (e *Endpoint) invoke(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	name := vars["name"]
+	name := funcName(r)
 
 	data, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -89,12 +367,40 @@ func (e *Endpoint) invoke(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	e.logger.Info("got lambda request", slog.Any("func_name", name))
+	ctx, invocationID := ensureInvocationID(r.Context())
+	w.Header().Set(invocationIDHeader, invocationID)
+
+	e.logger.Info("got lambda request", slog.Any("func_name", name), slog.String("invocation_id", invocationID))
+
+	var respData []byte
+
+	if key := r.Header.Get(idempotencyHeader); key != "" {
+		if len(key) > idempotencyMaxKey {
+			http.Error(w, "idempotency key is too long", http.StatusBadRequest)
+			return
+		}
+
+		var replayed bool
+
+		respData, replayed, err = e.idempotency.do(ctx, idempotencyKey(name, key), func() ([]byte, error) {
+			return e.svc.Invoke(ctx, name, data)
+		})
+
+		if replayed {
+			e.logger.Info("lambda: idempotent replay", slog.Any("func_name", name))
+			w.Header().Set(idempotencyReplay, "true")
+		}
+	} else {
+		respData, err = e.svc.Invoke(ctx, name, data)
+	}
+
+	if n := logTail(r); n > 0 {
+		w.Header().Set(logResultHeader, logResult(e.svc.Logs(name, time.Time{}, invocationID), n))
+	}
 
-	respData, err := e.svc.Invoke(r.Context(), name, data)
 	if err != nil {
 		e.logger.Error("lambda: invoke service error", "err", err.Error())
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), httpStatus(err))
 		return
 	}
 
@@ -107,13 +413,18 @@ func (e *Endpoint) invoke(w http.ResponseWriter, r *http.Request) {
 
 // StartServer starts http-server.
 func (e *Endpoint) StartServer(ctx context.Context) error {
-	r := mux.NewRouter()
-	r.HandleFunc("/lambda/{name}/create", e.create).Methods(http.MethodPost)
-	r.HandleFunc("/lambda/{name}/invoke", e.invoke).Methods(http.MethodPost)
+	lis, err := net.Listen("tcp", e.serverAddr)
+	if err != nil {
+		return err
+	}
+
+	return e.Serve(ctx, lis)
+}
 
+// Serve accepts connections on the listener until context is canceled.
+func (e *Endpoint) Serve(ctx context.Context, lis net.Listener) error {
 	srv := &http.Server{
-		Handler:      r,
-		Addr:         e.serverAddr,
+		Handler:      e,
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
 	}
@@ -124,7 +435,15 @@ func (e *Endpoint) StartServer(ctx context.Context) error {
 		}
 	}()
 
-	e.logger.Info("server started", slog.String("addr", e.serverAddr))
+	if e.tlsCert != "" || e.tlsKey != "" {
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+
+		e.logger.Info("server started", slog.String("addr", lis.Addr().String()), slog.Bool("tls", true))
+
+		return srv.ServeTLS(lis, e.tlsCert, e.tlsKey)
+	}
+
+	e.logger.Info("server started", slog.String("addr", lis.Addr().String()))
 
-	return srv.ListenAndServe()
+	return srv.Serve(lis)
 }
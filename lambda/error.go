@@ -1,6 +1,62 @@
 package lambda
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrThrottled is returned when the function has reached its concurrency limit.
+	ErrThrottled = errors.New("function concurrency limit exceeded")
+	// ErrFunctionNotFound is returned when the function is not registered.
+	ErrFunctionNotFound = errors.New("function not found")
+	// ErrFunctionExists is returned when the function to create is already registered.
+	ErrFunctionExists = errors.New("function already exists")
+	// ErrFunctionUnavailable is returned when the function container does not accept connections.
+	ErrFunctionUnavailable = errors.New("function unavailable")
+)
+
+// FunctionError is the error returned by the function handler with its gRPC status.
+type FunctionError struct {
+	Code    codes.Code
+	Message string
+}
+
+func (e *FunctionError) Error() string {
+	return "function: " + e.Message
+}
+
+// GRPCStatus returns the status of the handler response, so it is passed through gRPC as is.
+func (e *FunctionError) GRPCStatus() *status.Status {
+	return status.New(e.Code, e.Message)
+}
+
+// Unwrap returns context error for the deadline exceeded and canceled invocations.
+func (e *FunctionError) Unwrap() error {
+	switch e.Code {
+	case codes.DeadlineExceeded:
+		return context.DeadlineExceeded
+	case codes.Canceled:
+		return context.Canceled
+	default:
+		return nil
+	}
+}
+
+// functionError returns the error of the function call as *FunctionError if it has gRPC status.
+func functionError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	return &FunctionError{Code: st.Code(), Message: st.Message()}
+}
 
 type Error struct {
 	Status  int    `json:"status"`
@@ -19,3 +75,54 @@ func (e Error) Error() []byte {
 
 	return data
 }
+
+// httpStatus returns HTTP status code for the service error.
+func httpStatus(err error) int {
+	if errors.Is(err, ErrThrottled) || errors.Is(err, ErrQuotaExceeded) {
+		return http.StatusTooManyRequests
+	}
+
+	if errors.Is(err, ErrFunctionNotFound) {
+		return http.StatusNotFound
+	}
+
+	if errors.Is(err, ErrFunctionUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+
+	var fnErr *FunctionError
+	if errors.As(err, &fnErr) {
+		return functionStatus(fnErr.Code)
+	}
+
+	return http.StatusBadRequest
+}
+
+// functionStatus returns HTTP status code for the handler status code.
+// Plain handler errors have Unknown code and are reported as bad request.
+func functionStatus(code codes.Code) int {
+	switch code {
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Internal, codes.DataLoss:
+		return http.StatusInternalServerError
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadRequest
+	}
+}
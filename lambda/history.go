@@ -0,0 +1,258 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// replayCurrentVersion is the only version invocation could be replayed against.
+const replayCurrentVersion = "current"
+
+var (
+	errInvocationNotFound = errors.New("invocation not found")
+	errPayloadNotRecorded = errors.New("invocation payload was not recorded or was truncated")
+	errReplayFunction     = errors.New("invocation is replayed against the current deployment of its own function only")
+	errReplayVersion      = errors.New("functions are not versioned, invocation is replayed against the current deployment only")
+)
+
+// Invocation is a record of the function invocation.
+type Invocation struct {
+	ID                string    `json:"id"`
+	Function          string    `json:"function"`
+	StartedAt         time.Time `json:"started_at"`
+	FinishedAt        time.Time `json:"finished_at"`
+	DurationMs        int64     `json:"duration_ms"`
+	Status            string    `json:"status"`
+	Error             string    `json:"error,omitempty"`
+	ReplayOf          string    `json:"replay_of,omitempty"`
+	Payload           []byte    `json:"payload,omitempty"`
+	PayloadTruncated  bool      `json:"payload_truncated,omitempty"`
+	Response          []byte    `json:"response,omitempty"`
+	ResponseTruncated bool      `json:"response_truncated,omitempty"`
+}
+
+// historyStore keeps the last invocations per function.
+type historyStore struct {
+	mu    sync.RWMutex
+	limit int
+	byFn  map[string][]*Invocation
+	byID  map[string]*Invocation
+}
+
+func newHistoryStore(limit int) *historyStore {
+	return &historyStore{
+		limit: limit,
+		byFn:  make(map[string][]*Invocation),
+		byID:  make(map[string]*Invocation),
+	}
+}
+
+// add stores the invocation evicting the oldest one of the function.
+func (h *historyStore) add(inv *Invocation) {
+	if h.limit <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	list := append(h.byFn[inv.Function], inv)
+
+	if len(list) > h.limit {
+		for _, old := range list[:len(list)-h.limit] {
+			delete(h.byID, old.ID)
+		}
+
+		list = list[len(list)-h.limit:]
+	}
+
+	h.byFn[inv.Function] = list
+	h.byID[inv.ID] = inv
+}
+
+// list returns the last n invocations of the function, newest first.
+func (h *historyStore) list(name string, n int) []Invocation {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	list := h.byFn[name]
+	if n <= 0 || n > len(list) {
+		n = len(list)
+	}
+
+	result := make([]Invocation, 0, n)
+	for i := len(list) - 1; i >= len(list)-n; i-- {
+		result = append(result, *list[i])
+	}
+
+	return result
+}
+
+// get returns invocation by its ID.
+func (h *historyStore) get(id string) (Invocation, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	inv, ok := h.byID[id]
+	if !ok {
+		return Invocation{}, false
+	}
+
+	return *inv, true
+}
+
+// capped returns the copy of data limited by size and whether it was truncated.
+func capped(data []byte, limit int) ([]byte, bool) {
+	if len(data) > limit {
+		return append([]byte(nil), data[:limit]...), true
+	}
+
+	return append([]byte(nil), data...), false
+}
+
+// record stores the finished invocation in the history.
+func (s *Service) record(ctx context.Context, name string, startedAt time.Time, data, resp []byte, err error) {
+	finishedAt := time.Now()
+
+	inv := &Invocation{
+		ID:         InvocationID(ctx),
+		Function:   name,
+		StartedAt:  startedAt,
+		FinishedAt: finishedAt,
+		DurationMs: finishedAt.Sub(startedAt).Milliseconds(),
+		Status:     metricStatus(err),
+		ReplayOf:   replayOf(ctx),
+	}
+
+	if err != nil {
+		inv.Error = err.Error()
+	}
+
+	if s.cfg.App.HistoryPayloads {
+		limit := s.cfg.App.HistoryPayloadLimit
+
+		inv.Payload, inv.PayloadTruncated = capped(data, limit)
+
+		if resp != nil {
+			inv.Response, inv.ResponseTruncated = capped(resp, limit)
+		}
+	}
+
+	s.history.add(inv)
+}
+
+// Invocations returns the last n invocations of the function, newest first.
+func (s *Service) Invocations(name string, n int) []Invocation {
+	return s.history.list(name, n)
+}
+
+// Invocation returns recorded invocation by its ID.
+func (s *Service) Invocation(id string) (Invocation, bool) {
+	return s.history.get(id)
+}
+
+// Replay invokes the current version of the function with the recorded payload of the invocation.
+// Functions are not versioned, so the name must be empty or equal to the function of the original invocation.
+func (s *Service) Replay(ctx context.Context, id, name string) ([]byte, error) {
+	inv, ok := s.history.get(id)
+	if !ok {
+		return nil, errInvocationNotFound
+	}
+
+	if !s.cfg.App.HistoryPayloads || inv.PayloadTruncated {
+		return nil, errPayloadNotRecorded
+	}
+
+	if name != "" && name != inv.Function {
+		return nil, fmt.Errorf("%w: %s", errReplayFunction, inv.Function)
+	}
+
+	name = inv.Function
+
+	s.log.Info("replay invocation", slog.String("id", id), slog.String("func_name", name))
+
+	return s.Invoke(withReplayOf(ctx, id), name, inv.Payload)
+}
+
+// invocations http endpoint for list the last invocations of the function.
+func (e *Endpoint) invocations(w http.ResponseWriter, r *http.Request) {
+	name := funcName(r)
+
+	var limit int
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+
+		if limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	e.writeJSON(w, http.StatusOK, e.svc.Invocations(name, limit))
+}
+
+// replay http endpoint for re-run the recorded invocation payload.
+// Optional function parameter ("namespace/name" or "name") must be the function of the recorded invocation,
+// functions are not versioned, so version parameter is rejected unless it is "current".
+func (e *Endpoint) replay(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	ctx, invocationID := ensureInvocationID(r.Context())
+	w.Header().Set(invocationIDHeader, invocationID)
+
+	e.logger.Info("got replay request", slog.String("id", id), slog.String("invocation_id", invocationID))
+
+	if v := r.URL.Query().Get("version"); v != "" && v != replayCurrentVersion {
+		http.Error(w, errReplayVersion.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var name string
+
+	if ref := r.URL.Query().Get("function"); ref != "" {
+		var err error
+
+		if name, err = parseFunction(ref); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if inv, ok := e.svc.Invocation(id); ok && !e.checkAccess(r, ScopeFunctionInvoke, inv.Function) {
+		http.Error(w, errForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	respData, err := e.svc.Replay(ctx, id, name)
+	if err != nil {
+		e.logger.Error("replay: service error", "err", err.Error())
+
+		status := httpStatus(err)
+
+		switch {
+		case errors.Is(err, errInvocationNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, errPayloadNotRecorded):
+			status = http.StatusConflict
+		case errors.Is(err, errReplayFunction):
+			status = http.StatusBadRequest
+		}
+
+		http.Error(w, err.Error(), status)
+
+		return
+	}
+
+	if _, err := w.Write(respData); err != nil {
+		e.logger.Error("replay: write error", "err", err.Error())
+	}
+}
@@ -0,0 +1,134 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Idempotency-Key header lets clients safely retry invocation, the first result is replayed for the same key.
+const (
+	idempotencyHeader   = "Idempotency-Key"
+	idempotencyReplay   = "Idempotent-Replayed"
+	idempotencyMaxKey   = 255
+	idempotencySweepGap = time.Minute
+)
+
+// idempotencyEntry is a result of the invocation stored by idempotency key.
+type idempotencyEntry struct {
+	done      chan struct{}
+	data      []byte
+	err       error
+	expiresAt time.Time
+}
+
+// idempotencyStore is a TTL-bounded local store of invocation results.
+type idempotencyStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]*idempotencyEntry
+	lastSweep time.Time
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{ttl: ttl, entries: make(map[string]*idempotencyEntry)}
+}
+
+// do returns stored result for the key or calls fn and stores its result.
+// Concurrent calls with the same key wait for the in-flight result instead of calling fn twice,
+// if the in-flight call is interrupted, the waiting call calls fn itself.
+func (s *idempotencyStore) do(
+	ctx context.Context,
+	key string,
+	fn func() ([]byte, error),
+) (data []byte, replayed bool, err error) {
+	var entry *idempotencyEntry
+
+	for {
+		now := time.Now()
+
+		s.mu.Lock()
+		s.sweep(now)
+
+		waiting, ok := s.entries[key]
+		if !ok || (!waiting.expiresAt.IsZero() && !waiting.expiresAt.After(now)) {
+			entry = &idempotencyEntry{done: make(chan struct{})}
+			s.entries[key] = entry
+		}
+		s.mu.Unlock()
+
+		if entry != nil {
+			break
+		}
+
+		select {
+		case <-waiting.done:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+
+		// the key of the interrupted call is released, so it is tried again.
+		if final(waiting.err) {
+			return waiting.data, true, waiting.err
+		}
+	}
+
+	entry.data, entry.err = fn()
+
+	s.mu.Lock()
+	if final(entry.err) {
+		entry.expiresAt = time.Now().Add(s.ttl)
+	} else {
+		// the function was not called or its call was interrupted, so the key can be used again.
+		delete(s.entries, key)
+	}
+	s.mu.Unlock()
+
+	close(entry.done)
+
+	return entry.data, false, entry.err
+}
+
+// final reports whether the invocation result is the function outcome to replay:
+// the response or the handler error. Control plane rejections, e.g. throttling, quota or unavailable function,
+// and interrupted calls are not stored.
+func final(err error) bool {
+	if err == nil {
+		return true
+	}
+
+	var fnErr *FunctionError
+	if !errors.As(err, &fnErr) {
+		return false
+	}
+
+	switch fnErr.Code {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Unavailable:
+		return false
+	default:
+		return true
+	}
+}
+
+// sweep removes expired entries. Must be called with the lock held.
+func (s *idempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < idempotencySweepGap {
+		return
+	}
+
+	for key, entry := range s.entries {
+		if !entry.expiresAt.IsZero() && !entry.expiresAt.After(now) {
+			delete(s.entries, key)
+		}
+	}
+
+	s.lastSweep = now
+}
+
+// idempotencyKey returns store key for the function and client key.
+func idempotencyKey(name, key string) string {
+	return name + "\x00" + key
+}
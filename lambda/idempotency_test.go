@@ -0,0 +1,121 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestIdempotencyStoreDo(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		stored bool
+	}{
+		{name: "success", err: nil, stored: true},
+		{name: "handler error", err: &FunctionError{Code: codes.Unknown, Message: "boom"}, stored: true},
+		{name: "wrapped handler error", err: fmt.Errorf("make request: %w", &FunctionError{Code: codes.NotFound}), stored: true},
+		{name: "handler deadline", err: &FunctionError{Code: codes.DeadlineExceeded}, stored: false},
+		{name: "unavailable", err: fmt.Errorf("%w: refused", ErrFunctionUnavailable), stored: false},
+		{name: "throttled", err: ErrThrottled, stored: false},
+		{name: "quota", err: ErrQuotaExceeded, stored: false},
+		{name: "not found", err: ErrFunctionNotFound, stored: false},
+		{name: "canceled", err: context.Canceled, stored: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newIdempotencyStore(time.Hour)
+
+			var calls int
+
+			fn := func() ([]byte, error) {
+				calls++
+				return []byte("ok"), tt.err
+			}
+
+			if _, replayed, _ := store.do(context.Background(), "key", fn); replayed {
+				t.Fatal("first call is replayed")
+			}
+
+			_, replayed, err := store.do(context.Background(), "key", fn)
+
+			if replayed != tt.stored {
+				t.Fatalf("replayed = %v, want %v", replayed, tt.stored)
+			}
+
+			if want := map[bool]int{true: 1, false: 2}[tt.stored]; calls != want {
+				t.Fatalf("calls = %d, want %d", calls, want)
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+		})
+
+		t.Run(tt.name+" waiting", func(t *testing.T) {
+			store := newIdempotencyStore(time.Hour)
+			release := make(chan struct{})
+			first := make(chan error, 1)
+
+			go func() {
+				_, _, err := store.do(context.Background(), "key", func() ([]byte, error) {
+					<-release
+					return []byte("first"), tt.err
+				})
+				first <- err
+			}()
+
+			// the second call waits for the in-flight one.
+			for {
+				store.mu.Lock()
+				_, ok := store.entries["key"]
+				store.mu.Unlock()
+
+				if ok {
+					break
+				}
+
+				time.Sleep(time.Millisecond)
+			}
+
+			type result struct {
+				data     []byte
+				replayed bool
+				err      error
+			}
+
+			second := make(chan result, 1)
+
+			go func() {
+				data, replayed, err := store.do(context.Background(), "key", func() ([]byte, error) {
+					return []byte("second"), nil
+				})
+				second <- result{data: data, replayed: replayed, err: err}
+			}()
+
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+
+			if err := <-first; !errors.Is(err, tt.err) {
+				t.Fatalf("first call: %v", err)
+			}
+
+			got := <-second
+
+			// interrupted call is not replayed to the waiting call, it calls the function itself.
+			want := result{data: []byte("second")}
+			if tt.stored {
+				want = result{data: []byte("first"), replayed: true, err: tt.err}
+			}
+
+			if string(got.data) != string(want.data) || got.replayed != want.replayed || !errors.Is(got.err, want.err) {
+				t.Fatalf("second call: %q %v %v, want %q %v %v", got.data, got.replayed, got.err, want.data, want.replayed, want.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+
+	"github.com/ihippik/lambda-go/config"
+)
+
+// ClaimsMetadataKey is the invocation metadata key with JSON-encoded verified token claims of the caller.
+const ClaimsMetadataKey = "lambda-claims"
+
+const (
+	// jwtLeeway is the allowed clock skew for the time claims.
+	jwtLeeway = time.Minute
+	// jwksMinRefresh limits JWKS reloads on unknown key ID.
+	jwksMinRefresh = time.Minute
+	// jwtAllNamespaces is the namespaces claim value granting access to all namespaces.
+	jwtAllNamespaces = "*"
+)
+
+var errInvalidToken = errors.New("invalid bearer token")
+
+// jwtAlgorithms are the accepted signature algorithms, symmetric ones are not allowed with JWKS.
+var jwtAlgorithms = []string{
+	string(jose.RS256), string(jose.RS384), string(jose.RS512),
+	string(jose.PS256), string(jose.PS384), string(jose.PS512),
+	string(jose.ES256), string(jose.ES384), string(jose.ES512),
+	string(jose.EdDSA),
+}
+
+// jwtVerifier verifies bearer tokens against the JWKS loaded from the file or URL.
+type jwtVerifier struct {
+	cfg    config.AuthCfg
+	client *http.Client
+
+	mu       sync.RWMutex
+	keys     jose.JSONWebKeySet
+	loadedAt time.Time
+}
+
+// newJWTVerifier returns verifier with loaded keys or nil if JWKS is not configured.
+func newJWTVerifier(ctx context.Context, cfg config.AuthCfg) (*jwtVerifier, error) {
+	if cfg.JWKS == "" {
+		return nil, nil
+	}
+
+	if cfg.JWTIssuer == "" || cfg.JWTAudience == "" {
+		return nil, errors.New("JWT issuer and audience are required")
+	}
+
+	v := &jwtVerifier{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+
+	if err := v.load(ctx); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// remote reports whether JWKS is fetched by URL.
+func (v *jwtVerifier) remote() bool {
+	return strings.HasPrefix(v.cfg.JWKS, "http://") || strings.HasPrefix(v.cfg.JWKS, "https://")
+}
+
+// load reads JWKS from the file or fetches it by URL.
+func (v *jwtVerifier) load(ctx context.Context) error {
+	var (
+		data []byte
+		err  error
+	)
+
+	if v.remote() {
+		data, err = v.fetch(ctx)
+	} else {
+		data, err = os.ReadFile(v.cfg.JWKS)
+	}
+
+	if err != nil {
+		return fmt.Errorf("load JWKS: %w", err)
+	}
+
+	var keys jose.JSONWebKeySet
+
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("unmarshal JWKS: %w", err)
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.loadedAt = time.Now()
+	v.mu.Unlock()
+
+	return nil
+}
+
+// fetch downloads JWKS by URL.
+func (v *jwtVerifier) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.JWKS, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// lookup returns keys with the key ID. Remote JWKS is reloaded when it is stale or the key is unknown.
+func (v *jwtVerifier) lookup(ctx context.Context, kid string) []jose.JSONWebKey {
+	v.mu.RLock()
+	keys := v.keys.Key(kid)
+	age := time.Since(v.loadedAt)
+	v.mu.RUnlock()
+
+	if !v.remote() {
+		return keys
+	}
+
+	if age > v.cfg.JWKSRefresh || len(keys) == 0 && age > jwksMinRefresh {
+		// stale keys are still used if the identity provider is unavailable.
+		if err := v.load(ctx); err == nil {
+			v.mu.RLock()
+			keys = v.keys.Key(kid)
+			v.mu.RUnlock()
+		}
+	}
+
+	return keys
+}
+
+// verify checks token signature, issuer, audience and expiry and returns the principal mapped from its claims.
+func (v *jwtVerifier) verify(ctx context.Context, token string) (*Principal, error) {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	if len(tok.Headers) != 1 || !slices.Contains(jwtAlgorithms, tok.Headers[0].Algorithm) {
+		return nil, errInvalidToken
+	}
+
+	header := tok.Headers[0]
+
+	var (
+		claims jwt.Claims
+		raw    map[string]any
+	)
+
+	verified := false
+
+	for _, key := range v.lookup(ctx, header.KeyID) {
+		if (key.Algorithm != "" && key.Algorithm != header.Algorithm) || key.Use == "enc" {
+			continue
+		}
+
+		if err := tok.Claims(key.Key, &claims, &raw); err == nil {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return nil, errInvalidToken
+	}
+
+	if claims.Expiry == nil {
+		return nil, fmt.Errorf("%w: exp claim is required", errInvalidToken)
+	}
+
+	expected := jwt.Expected{Issuer: v.cfg.JWTIssuer, Audience: jwt.Audience{v.cfg.JWTAudience}, Time: time.Now()}
+
+	if err := claims.ValidateWithLeeway(expected, jwtLeeway); err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidToken, err.Error())
+	}
+
+	p := &Principal{Subject: "jwt:" + claims.Subject, Claims: raw}
+
+	for _, scope := range claimStrings(raw[v.cfg.JWTScopesClaim]) {
+		if slices.Contains(knownScopes, scope) {
+			p.Scopes = append(p.Scopes, scope)
+		}
+	}
+
+	// access to all namespaces is granted explicitly, token without namespaces is denied.
+	namespaces := claimStrings(raw[v.cfg.JWTNamespacesClaim])
+
+	switch {
+	case len(namespaces) == 0:
+		return nil, fmt.Errorf("%w: %s claim is required", errInvalidToken, v.cfg.JWTNamespacesClaim)
+	case !slices.Contains(namespaces, jwtAllNamespaces):
+		p.Namespaces = namespaces
+	}
+
+	return p, nil
+}
+
+// claimStrings returns values of the claim which is either space-separated string or array of strings.
+func claimStrings(claim any) []string {
+	switch c := claim.(type) {
+	case string:
+		return strings.Fields(c)
+	case []any:
+		values := make([]string, 0, len(c))
+
+		for _, v := range c {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+
+		return values
+	default:
+		return nil
+	}
+}
+
+// requestMetadata returns invocation metadata sent to the function: trace context and verified token claims.
+func requestMetadata(ctx context.Context) map[string]string {
+	metadata := injectTrace(ctx)
+
+	if p, ok := PrincipalFromContext(ctx); ok && len(p.Claims) > 0 {
+		if data, err := json.Marshal(p.Claims); err == nil {
+			metadata[ClaimsMetadataKey] = string(data)
+		}
+	}
+
+	return metadata
+}
+
+// ClaimsFromContext returns verified token claims of the caller which invoked the function.
+// It is available in the handler if the function was invoked with the bearer token.
+func ClaimsFromContext(ctx context.Context) (map[string]any, bool) {
+	claims, ok := ctx.Value(claimsKey).(map[string]any)
+	return claims, ok
+}
+
+// withClaims returns a copy of the context with token claims from the invocation metadata.
+func withClaims(ctx context.Context, metadata map[string]string) context.Context {
+	data, ok := metadata[ClaimsMetadataKey]
+	if !ok {
+		return ctx
+	}
+
+	var claims map[string]any
+
+	if err := json.Unmarshal([]byte(data), &claims); err != nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, claimsKey, claims)
+}
@@ -0,0 +1,112 @@
+package lambda
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+
+	"github.com/ihippik/lambda-go/config"
+)
+
+func TestJWTVerifierNamespaces(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: key.Public(), KeyID: "test", Algorithm: string(jose.ES256), Use: "sig"},
+	}})
+	if err != nil {
+		t.Fatalf("marshal jwks: %v", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "jwks.json")
+
+	if err := os.WriteFile(file, jwks, 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+
+	cfg := config.AuthCfg{
+		JWKS:               file,
+		JWTIssuer:          "issuer",
+		JWTAudience:        "lambda",
+		JWTScopesClaim:     "scope",
+		JWTNamespacesClaim: "namespaces",
+	}
+
+	v, err := newJWTVerifier(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.ES256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"),
+	)
+	if err != nil {
+		t.Fatalf("new signer: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		namespaces any
+		want       []string
+		wantErr    bool
+	}{
+		{name: "missing claim", namespaces: nil, wantErr: true},
+		{name: "empty claim", namespaces: "", wantErr: true},
+		{name: "namespaces", namespaces: []string{"team-a", "team-b"}, want: []string{"team-a", "team-b"}},
+		{name: "space-separated", namespaces: "team-a team-b", want: []string{"team-a", "team-b"}},
+		{name: "wildcard", namespaces: "*", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := map[string]any{
+				"sub":   "user",
+				"iss":   cfg.JWTIssuer,
+				"aud":   cfg.JWTAudience,
+				"exp":   jwt.NewNumericDate(time.Now().Add(time.Minute)),
+				"scope": ScopeFunctionInvoke,
+			}
+
+			if tt.namespaces != nil {
+				claims["namespaces"] = tt.namespaces
+			}
+
+			token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+			if err != nil {
+				t.Fatalf("sign token: %v", err)
+			}
+
+			p, err := v.verify(context.Background(), token)
+			if tt.wantErr {
+				if !errors.Is(err, errInvalidToken) {
+					t.Fatalf("expected invalid token error, got %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+
+			if !slices.Equal(p.Namespaces, tt.want) {
+				t.Fatalf("namespaces %v, want %v", p.Namespaces, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+// Package lambdatest serves the lambda handler in-process for unit tests of the function.
+// Handler is served by the same gRPC server as in the function container and invoked with the same
+// payload as the control plane sends, so serialization, invocation context and errors are exercised.
+package lambdatest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/ihippik/lambda-go/lambda"
+	"github.com/ihippik/lambda-go/lambda/proto"
+)
+
+const bufSize = 1 << 20
+
+type contextKey int
+
+const (
+	invocationIDKey contextKey = iota
+	claimsKey
+)
+
+// Error is the handler error as the control plane returns it, with the handler gRPC status.
+type Error = lambda.FunctionError
+
+// Option configures the server.
+type Option func(*options)
+
+type options struct {
+	tcp bool
+}
+
+// WithTCP serves the handler on the ephemeral loopback port instead of in-memory connection.
+func WithTCP() Option {
+	return func(o *options) {
+		o.tcp = true
+	}
+}
+
+// WithInvocationID returns a copy of the context with invocation ID sent to the handler.
+// Random invocation ID is sent if it is not set, as the control plane does.
+func WithInvocationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, invocationIDKey, id)
+}
+
+// WithClaims returns a copy of the context with caller token claims sent to the handler.
+func WithClaims(ctx context.Context, claims map[string]any) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// Client invokes the handler served in-process.
+type Client struct {
+	// Addr is the address of the handler server, it is "bufconn" for in-memory connection.
+	Addr string
+
+	srv    *grpc.Server
+	conn   *grpc.ClientConn
+	client proto.LambdaServerClient
+	done   chan struct{}
+}
+
+// Start serves the lambda server and connects to it.
+func Start(server *lambda.Server, opts ...Option) (*Client, error) {
+	var o options
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var (
+		lis      net.Listener
+		dialOpts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+		target   = "bufconn"
+	)
+
+	if o.tcp {
+		var err error
+
+		if lis, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+			return nil, fmt.Errorf("listen: %w", err)
+		}
+
+		target = lis.Addr().String()
+	} else {
+		buf := bufconn.Listen(bufSize)
+		lis = buf
+
+		dialOpts = append(dialOpts, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return buf.DialContext(ctx)
+		}))
+	}
+
+	c := &Client{Addr: target, srv: grpc.NewServer(), done: make(chan struct{})}
+
+	server.Register(c.srv)
+
+	go func() {
+		defer close(c.done)
+		_ = c.srv.Serve(lis)
+	}()
+
+	conn, err := grpc.Dial(target, dialOpts...)
+	if err != nil {
+		c.srv.Stop()
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+
+	c.conn = conn
+	c.client = proto.NewLambdaServerClient(conn)
+
+	return c, nil
+}
+
+// New serves the handler for the test, the server is stopped on the test cleanup.
+func New(t testing.TB, handler lambda.Handler, opts ...Option) *Client {
+	t.Helper()
+
+	return newClient(t, lambda.NewServer(handler), opts)
+}
+
+// NewStreaming serves the streaming handler for the test.
+func NewStreaming(t testing.TB, handler lambda.StreamHandler, opts ...Option) *Client {
+	t.Helper()
+
+	return newClient(t, lambda.NewStreamingServer(handler), opts)
+}
+
+func newClient(t testing.TB, server *lambda.Server, opts []Option) *Client {
+	t.Helper()
+
+	c, err := Start(server, opts...)
+	if err != nil {
+		t.Fatalf("lambdatest: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			t.Errorf("lambdatest: close: %v", err)
+		}
+	})
+
+	return c
+}
+
+// Invoke invokes the handler with the payload and returns its response.
+// Context deadline is propagated to the handler, handler error is returned as *Error.
+func (c *Client) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	req, err := request(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.MakeRequest(ctx, req)
+	if err != nil {
+		return nil, invokeError(err)
+	}
+
+	return resp.Data, nil
+}
+
+// InvokeStream invokes the handler and writes its response chunks to w as they are received.
+func (c *Client) InvokeStream(ctx context.Context, payload []byte, w io.Writer) error {
+	req, err := request(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	stream, err := c.client.StreamRequest(ctx, req)
+	if err != nil {
+		return invokeError(err)
+	}
+
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return invokeError(err)
+		}
+
+		if _, err := w.Write(chunk.Data); err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+	}
+}
+
+// Close closes the connection and stops the server.
+func (c *Client) Close() error {
+	err := c.conn.Close()
+
+	c.srv.Stop()
+	<-c.done
+
+	return err
+}
+
+// request returns the payload as the control plane sends it.
+func request(ctx context.Context, payload []byte) (*proto.Payload, error) {
+	id, _ := ctx.Value(invocationIDKey).(string)
+	if id == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("generate invocation id: %w", err)
+		}
+
+		id = hex.EncodeToString(b)
+	}
+
+	metadata := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, metadata)
+
+	if claims, ok := ctx.Value(claimsKey).(map[string]any); ok {
+		data, err := json.Marshal(claims)
+		if err != nil {
+			return nil, fmt.Errorf("marshal claims: %w", err)
+		}
+
+		metadata[lambda.ClaimsMetadataKey] = string(data)
+	}
+
+	return &proto.Payload{Data: payload, InvocationId: id, Metadata: metadata}, nil
+}
+
+// invokeError converts the call error as the control plane does.
+func invokeError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	return &Error{Code: st.Code(), Message: st.Message()}
+}
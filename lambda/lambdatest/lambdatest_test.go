@@ -0,0 +1,136 @@
+package lambdatest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ihippik/lambda-go/lambda"
+	"github.com/ihippik/lambda-go/lambda/lambdatest"
+)
+
+func TestInvoke(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []lambdatest.Option
+	}{
+		{name: "bufconn"},
+		{name: "tcp", opts: []lambdatest.Option{lambdatest.WithTCP()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := lambdatest.New(t, func(_ context.Context, payload []byte) ([]byte, error) {
+				return append([]byte("hello "), payload...), nil
+			}, tc.opts...)
+
+			resp, err := c.Invoke(context.Background(), []byte("Ivan"))
+			if err != nil {
+				t.Fatalf("invoke: %v", err)
+			}
+
+			if string(resp) != "hello Ivan" {
+				t.Fatalf("unexpected response %q", resp)
+			}
+		})
+	}
+}
+
+func TestInvokeContext(t *testing.T) {
+	c := lambdatest.New(t, func(ctx context.Context, _ []byte) ([]byte, error) {
+		claims, _ := lambda.ClaimsFromContext(ctx)
+		return []byte(fmt.Sprintf("%s %v", lambda.InvocationID(ctx), claims["sub"])), nil
+	})
+
+	ctx := lambdatest.WithInvocationID(context.Background(), "inv-1")
+	ctx = lambdatest.WithClaims(ctx, map[string]any{"sub": "user-1"})
+
+	resp, err := c.Invoke(ctx, nil)
+	if err != nil {
+		t.Fatalf("invoke: %v", err)
+	}
+
+	if string(resp) != "inv-1 user-1" {
+		t.Fatalf("unexpected response %q", resp)
+	}
+}
+
+func TestInvokeError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    codes.Code
+		message string
+	}{
+		{name: "plain", err: errors.New("boom"), code: codes.Unknown, message: "handler: boom"},
+		{name: "status", err: status.Error(codes.NotFound, "no user"), code: codes.NotFound, message: "no user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+
+			c := lambdatest.New(t, func(context.Context, []byte) ([]byte, error) {
+				calls++
+				return nil, tt.err
+			})
+
+			_, err := c.Invoke(context.Background(), nil)
+
+			var fnErr *lambda.FunctionError
+			if !errors.As(err, &fnErr) {
+				t.Fatalf("expected *lambda.FunctionError, got %T: %v", err, err)
+			}
+
+			if fnErr.Code != tt.code || fnErr.Message != tt.message {
+				t.Fatalf("unexpected error %s: %q", fnErr.Code, fnErr.Message)
+			}
+
+			if calls != 1 {
+				t.Fatalf("handler is called %d times", calls)
+			}
+		})
+	}
+}
+
+func TestInvokeDeadline(t *testing.T) {
+	c := lambdatest.New(t, func(ctx context.Context, _ []byte) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Invoke(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestInvokeStream(t *testing.T) {
+	c := lambdatest.NewStreaming(t, func(_ context.Context, _ []byte, w io.Writer) error {
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "chunk %d\n", i)
+		}
+
+		return status.Error(codes.Internal, "stopped")
+	})
+
+	var buf bytes.Buffer
+
+	err := c.InvokeStream(context.Background(), nil, &buf)
+
+	if buf.String() != "chunk 0\nchunk 1\nchunk 2\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+
+	var fnErr *lambdatest.Error
+	if !errors.As(err, &fnErr) || fnErr.Code != codes.Internal {
+		t.Fatalf("expected internal error, got %v", err)
+	}
+}
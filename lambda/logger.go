@@ -0,0 +1,90 @@
+package lambda
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// invocationKey is a log attribute key with the invocation ID.
+const invocationKey = "invocation_id"
+
+// logger writes JSON to stdout captured by the control plane and tags every record with the invocation ID.
+var logger = slog.New(logHandler{Handler: slog.NewJSONHandler(os.Stdout, nil)})
+
+// Logger returns the function logger which writes JSON to stdout and tags every record with the invocation ID.
+// The default slog logger is left to the application, so only records of Logger are attributed to the invocation:
+// lines written by slog.Info and other default logger calls are captured without the invocation ID.
+func Logger() *slog.Logger {
+	return logger
+}
+
+// inflight tracks invocations which are being handled by the function.
+type inflight struct {
+	mu  sync.Mutex
+	ids map[string]struct{}
+}
+
+func (f *inflight) add(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.ids == nil {
+		f.ids = make(map[string]struct{})
+	}
+
+	f.ids[id] = struct{}{}
+}
+
+func (f *inflight) remove(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.ids, id)
+}
+
+// single returns invocation ID if it is the only one in flight.
+func (f *inflight) single() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.ids) != 1 {
+		return ""
+	}
+
+	for id := range f.ids {
+		return id
+	}
+
+	return ""
+}
+
+var invocations inflight
+
+// logHandler tags every log record with the invocation ID.
+// ID is taken from the context, records without context are tagged if there is only one invocation in flight.
+type logHandler struct {
+	slog.Handler
+}
+
+func (h logHandler) Handle(ctx context.Context, r slog.Record) error {
+	id := InvocationID(ctx)
+	if id == "" {
+		id = invocations.single()
+	}
+
+	if id != "" {
+		r.AddAttrs(slog.String(invocationKey, id))
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return logHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h logHandler) WithGroup(name string) slog.Handler {
+	return logHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,30 @@
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	log := slog.New(logHandler{Handler: slog.NewJSONHandler(&buf, nil)})
+	log.InfoContext(withInvocationID(context.Background(), "inv-1"), "hello")
+
+	var record map[string]any
+
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal record: %v", err)
+	}
+
+	if record[invocationKey] != "inv-1" {
+		t.Fatalf("record is not tagged: %v", record)
+	}
+
+	if Logger() == slog.Default() {
+		t.Fatal("function logger replaces the default logger")
+	}
+}
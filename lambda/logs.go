@@ -0,0 +1,291 @@
+package lambda
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	invocationIDHeader = "X-Lambda-Invocation-Id"
+	logTailHeader      = "X-Lambda-Log-Tail"
+	logResultHeader    = "X-Lambda-Log-Result"
+	maxLogTail         = 100
+)
+
+// LogEntry is a line written by the function to stdout or stderr.
+type LogEntry struct {
+	Time         time.Time `json:"time"`
+	InvocationID string    `json:"invocation_id,omitempty"`
+	Stream       string    `json:"stream"`
+	Message      string    `json:"message"`
+}
+
+// match reports whether the entry satisfies the filter.
+func (l LogEntry) match(since time.Time, invocation string) bool {
+	if !since.IsZero() && l.Time.Before(since) {
+		return false
+	}
+
+	return invocation == "" || l.InvocationID == invocation
+}
+
+// parseLogLine makes log entry from the line, invocation ID is taken from JSON record.
+func parseLogLine(stream, line string) LogEntry {
+	entry := LogEntry{Time: time.Now(), Stream: stream, Message: line}
+
+	if strings.HasPrefix(line, "{") {
+		var rec map[string]any
+
+		if err := json.Unmarshal([]byte(line), &rec); err == nil {
+			entry.InvocationID, _ = rec[invocationKey].(string)
+		}
+	}
+
+	return entry
+}
+
+// logStore is a bounded per-function storage of log entries with live subscriptions.
+type logStore struct {
+	mu     sync.RWMutex
+	limit  int
+	logs   map[string][]LogEntry
+	tails  map[string]map[chan LogEntry]struct{}
+	logger *slog.Logger
+}
+
+func newLogStore(limit int, logger *slog.Logger) *logStore {
+	return &logStore{
+		limit:  limit,
+		logs:   make(map[string][]LogEntry),
+		tails:  make(map[string]map[chan LogEntry]struct{}),
+		logger: logger,
+	}
+}
+
+// add appends entry to the function logs evicting the oldest one and notifies subscribers.
+func (s *logStore) add(name string, entry LogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	logs := append(s.logs[name], entry)
+	if len(logs) > s.limit {
+		logs = logs[len(logs)-s.limit:]
+	}
+
+	s.logs[name] = logs
+
+	for ch := range s.tails[name] {
+		select {
+		case ch <- entry:
+		default:
+			s.logger.Warn("logs: slow subscriber, entry dropped", slog.String("func_name", name))
+		}
+	}
+}
+
+// list returns function logs matching the filter.
+func (s *logStore) list(name string, since time.Time, invocation string) []LogEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]LogEntry, 0)
+
+	for _, entry := range s.logs[name] {
+		if entry.match(since, invocation) {
+			result = append(result, entry)
+		}
+	}
+
+	return result
+}
+
+// subscribe returns channel with new function log entries, cancel func must be called to unsubscribe.
+func (s *logStore) subscribe(name string) (<-chan LogEntry, func()) {
+	const bufferSize = 100
+
+	ch := make(chan LogEntry, bufferSize)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tails[name] == nil {
+		s.tails[name] = make(map[chan LogEntry]struct{})
+	}
+
+	s.tails[name][ch] = struct{}{}
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.tails[name], ch)
+	}
+}
+
+// Logs returns captured function logs matching the filter.
+func (s *Service) Logs(name string, since time.Time, invocation string) []LogEntry {
+	return s.logs.list(name, since, invocation)
+}
+
+// TailLogs subscribes to the new function logs.
+func (s *Service) TailLogs(name string) (<-chan LogEntry, func()) {
+	return s.logs.subscribe(name)
+}
+
+// collectLogs captures container output into the log store until container is stopped.
+func (s *Service) collectLogs(name string, meta *metaData, since time.Time) {
+	done := make(chan struct{})
+	meta.logsDone = done
+
+	go func() {
+		defer close(done)
+
+		err := s.builder.ContainerLogs(context.Background(), meta.containerID, since, func(stream, line string) {
+			s.logs.add(name, parseLogLine(stream, line))
+		})
+		if err != nil {
+			s.log.Warn("collect logs", slog.String("func_name", name), "err", err.Error())
+		}
+	}()
+}
+
+// waitLogs waits until the logs of the stopped container are collected.
+func (s *Service) waitLogs(meta *metaData) {
+	const timeout = time.Second
+
+	if meta.logsDone == nil {
+		return
+	}
+
+	select {
+	case <-meta.logsDone:
+	case <-time.After(timeout):
+		s.log.Warn("collect logs: timeout", slog.String("id", meta.short()))
+	}
+}
+
+// logResult returns the last n lines of the invocation logs encoded for response header.
+func logResult(entries []LogEntry, n int) string {
+	if len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		lines[i] = entry.Message
+	}
+
+	return base64.StdEncoding.EncodeToString([]byte(strings.Join(lines, "\n")))
+}
+
+// logTail returns the number of log lines requested by client.
+func logTail(r *http.Request) int {
+	n, err := strconv.Atoi(r.Header.Get(logTailHeader))
+	if err != nil || n <= 0 {
+		return 0
+	}
+
+	return min(n, maxLogTail)
+}
+
+// parseSince parses since parameter as RFC3339 time or duration ago.
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("since must be RFC3339 time or duration: %w", err)
+	}
+
+	return time.Now().Add(-d), nil
+}
+
+// logs http endpoint for get function logs.
+// With follow=true parameter or Accept: text/event-stream header new entries are streamed as Server-Sent Events.
+func (e *Endpoint) logs(w http.ResponseWriter, r *http.Request) {
+	name := funcName(r)
+	query := r.URL.Query()
+
+	since, err := parseSince(query.Get("since"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	invocation := query.Get("invocation")
+
+	if query.Get("follow") != "true" && !strings.Contains(r.Header.Get("Accept"), eventStreamMIME) {
+		e.writeJSON(w, http.StatusOK, e.svc.Logs(name, since, invocation))
+		return
+	}
+
+	// subscribe before reading history to not miss entries in between.
+	tail, cancel := e.svc.TailLogs(name)
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		e.logger.Warn("logs: reset write deadline", "err", err.Error())
+	}
+
+	w.Header().Set("Content-Type", eventStreamMIME)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	send := func(entry LogEntry) error {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return err
+		}
+
+		return rc.Flush()
+	}
+
+	var last time.Time
+
+	for _, entry := range e.svc.Logs(name, since, invocation) {
+		if err := send(entry); err != nil {
+			return
+		}
+
+		last = entry.Time
+	}
+
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case entry := <-tail:
+			if !entry.match(since, invocation) || !entry.Time.After(last) {
+				continue
+			}
+
+			if err := send(entry); err != nil {
+				e.logger.Warn("logs: write error", "err", err.Error())
+				return
+			}
+		}
+	}
+}
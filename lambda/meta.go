@@ -1,21 +1,51 @@
 package lambda
 
-import "strconv"
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ihippik/lambda-go/builder"
+)
 
 type metaData struct {
 	containerID string
 	port        int
 	hotMode     bool
+	tls         bool
+	// network is the private network of the container, host is its address there.
+	network string
+	host    string
+	// opts are the container options to recreate it on host port conflict.
+	opts   builder.ContainerOptions
+	create CreateOptions
+	// egressToken authorizes the container in the egress proxy.
+	egressToken string
+	// certExpiry is the expiry of the function server certificate in the TLS directory.
+	certExpiry time.Time
+
+	mu       sync.Mutex
+	active   int
+	logsDone <-chan struct{}
+	deleted  bool
+	// retired is set when the function is updated, the container is removed after the last invocation.
+	retired bool
 }
 
 func newMetaData(containerID string, port int) *metaData {
 	return &metaData{containerID: containerID, port: port}
 }
 
-func (m metaData) address() string {
+// address returns gRPC address of the function, published host port is used if the container is not in the network.
+func (m *metaData) address() string {
+	if m.network != "" {
+		return net.JoinHostPort(m.host, builder.FunctionPort)
+	}
+
 	return ":" + strconv.Itoa(m.port)
 }
 
-func (m metaData) short() string {
+func (m *metaData) short() string {
 	return m.containerID[:5]
 }
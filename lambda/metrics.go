@@ -0,0 +1,95 @@
+package lambda
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "lambda"
+
+var (
+	invocationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "invocations_total",
+		Help:      "Number of function invocations by status.",
+	}, []string{"function", "status"})
+
+	invocationErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "invocation_errors_total",
+		Help:      "Number of failed function invocations.",
+	}, []string{"function"})
+
+	invocationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "invocation_duration_seconds",
+		Help:      "Duration of function invocations including container start.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"function"})
+
+	payloadSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "payload_size_bytes",
+		Help:      "Size of function request and response payloads.",
+		Buckets:   prometheus.ExponentialBuckets(64, 4, 10),
+	}, []string{"function", "direction"})
+
+	coldStartsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cold_starts_total",
+		Help:      "Number of invocations which had to start the function container.",
+	}, []string{"function"})
+
+	throttlesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "throttles_total",
+		Help:      "Number of invocations rejected by concurrency limit.",
+	}, []string{"function"})
+
+	buildDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "build_duration_seconds",
+		Help:      "Duration of function image builds.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+	}, []string{"status"})
+
+	buildFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "build_failures_total",
+		Help:      "Number of failed function image builds.",
+	})
+
+	runningContainers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "running_containers",
+		Help:      "Number of running function containers.",
+	})
+
+	auditFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "audit_failures_total",
+		Help:      "Number of control-plane actions which were not written to the audit log.",
+	}, []string{"action"})
+
+	egressBlockedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "egress_blocked_total",
+		Help:      "Number of function connections blocked by egress allow-list.",
+	}, []string{"function"})
+)
+
+// metricStatus returns status label value for the error.
+func metricStatus(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrThrottled):
+		return "throttled"
+	case errors.Is(err, ErrQuotaExceeded):
+		return "quota_exceeded"
+	default:
+		return "error"
+	}
+}
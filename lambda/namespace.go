@@ -0,0 +1,85 @@
+package lambda
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// DefaultNamespace is a namespace of the functions created without namespace prefix.
+const DefaultNamespace = "default"
+
+// Path variable patterns, dot is reserved as namespace and name separator in image tags.
+const (
+	namespacePattern = `[a-z0-9][a-z0-9-]{0,62}`
+	funcNamePattern  = `[A-Za-z0-9][A-Za-z0-9_-]{0,62}`
+)
+
+var (
+	namespaceRe = regexp.MustCompile(`^` + namespacePattern + `$`)
+	funcNameRe  = regexp.MustCompile(`^` + funcNamePattern + `$`)
+)
+
+// qualify returns registry key of the function in the namespace.
+func qualify(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// splitName splits registry key into namespace and function name.
+func splitName(qualified string) (string, string) {
+	namespace, name, ok := strings.Cut(qualified, "/")
+	if !ok {
+		return DefaultNamespace, qualified
+	}
+
+	return namespace, name
+}
+
+// parseFunction parses function reference in "namespace/name" or "name" form into registry key.
+func parseFunction(ref string) (string, error) {
+	namespace, name := splitName(ref)
+
+	if !namespaceRe.MatchString(namespace) {
+		return "", fmt.Errorf("invalid namespace %q", namespace)
+	}
+
+	if !funcNameRe.MatchString(name) {
+		return "", fmt.Errorf("invalid function name %q", name)
+	}
+
+	return qualify(namespace, name), nil
+}
+
+// requestNamespace returns namespace of the request, default namespace is used for routes without prefix.
+func requestNamespace(r *http.Request) string {
+	if namespace := mux.Vars(r)["namespace"]; namespace != "" {
+		return namespace
+	}
+
+	return DefaultNamespace
+}
+
+// funcName returns registry key of the function addressed by the request.
+func funcName(r *http.Request) string {
+	return qualify(requestNamespace(r), mux.Vars(r)["name"])
+}
+
+// ImageTag returns image tag the function is built with by the runtime backend.
+// Function is "namespace/name" or the name in the default namespace.
+func ImageTag(qualified string) string {
+	namespace, name := splitName(qualified)
+	return namespace + "." + name
+}
+
+// containerName returns container name of the function.
+func containerName(qualified string) string {
+	return "go-lambda-" + ImageTag(qualified)
+}
+
+// list http endpoint for list functions of the namespace.
+func (e *Endpoint) list(w http.ResponseWriter, r *http.Request) {
+	e.writeJSON(w, http.StatusOK, e.svc.List(requestNamespace(r)))
+}
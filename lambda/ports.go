@@ -0,0 +1,93 @@
+package lambda
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNoPortsAvailable is returned when all ports of the range are in use.
+var ErrNoPortsAvailable = errors.New("no ports available")
+
+// portAllocator assigns host ports of the range to the functions in host-port network mode.
+type portAllocator struct {
+	mu     sync.Mutex
+	min    int
+	max    int
+	hostIP string
+	next   int
+	used   map[int]string
+}
+
+func newPortAllocator(min, max int, hostIP string) (*portAllocator, error) {
+	if min < 1024 || max > 65535 || min > max {
+		return nil, fmt.Errorf("invalid port range %d-%d", min, max)
+	}
+
+	return &portAllocator{min: min, max: max, hostIP: hostIP, next: min, used: make(map[int]string)}, nil
+}
+
+// reserve records the port assigned to the function, e.g. of the container created before restart.
+func (a *portAllocator) reserve(port int, name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.used[port] = name
+}
+
+// allocate returns the port which is neither assigned nor bound by another process.
+// Ports are taken round-robin, so the recently released port is not reused at once.
+func (a *portAllocator) allocate(name string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	size := a.max - a.min + 1
+
+	for i := 0; i < size; i++ {
+		port := a.next
+
+		a.next++
+		if a.next > a.max {
+			a.next = a.min
+		}
+
+		if _, ok := a.used[port]; ok || !a.available(port) {
+			continue
+		}
+
+		a.used[port] = name
+
+		return port, nil
+	}
+
+	return 0, ErrNoPortsAvailable
+}
+
+// release makes the port available for allocation.
+func (a *portAllocator) release(port int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.used, port)
+}
+
+// available probes the port by binding it.
+func (a *portAllocator) available(port int) bool {
+	lis, err := net.Listen("tcp", net.JoinHostPort(a.hostIP, strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+
+	_ = lis.Close()
+
+	return true
+}
+
+// isBindConflict reports whether the container failed to start because the host port is taken.
+func isBindConflict(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "port is already allocated") || strings.Contains(msg, "address already in use")
+}
@@ -0,0 +1,454 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v4.24.3
+// source: control.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ControlPlaneClient is the client API for ControlPlane service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ControlPlaneClient interface {
+	// CreateFunction builds the function from the uploaded tar.gz archive of its sources.
+	// The first message is the spec, archive chunks follow.
+	CreateFunction(ctx context.Context, opts ...grpc.CallOption) (ControlPlane_CreateFunctionClient, error)
+	// UpdateFunction builds the new version of the function and swaps it with the running one.
+	UpdateFunction(ctx context.Context, opts ...grpc.CallOption) (ControlPlane_UpdateFunctionClient, error)
+	DeleteFunction(ctx context.Context, in *DeleteFunctionRequest, opts ...grpc.CallOption) (*DeleteFunctionResponse, error)
+	ListFunctions(ctx context.Context, in *ListFunctionsRequest, opts ...grpc.CallOption) (*ListFunctionsResponse, error)
+	Invoke(ctx context.Context, in *InvokeRequest, opts ...grpc.CallOption) (*InvokeResponse, error)
+	// InvokeStream returns the function response as it is streamed.
+	InvokeStream(ctx context.Context, in *InvokeRequest, opts ...grpc.CallOption) (ControlPlane_InvokeStreamClient, error)
+	// TailLogs returns captured function logs and then new entries until canceled.
+	TailLogs(ctx context.Context, in *TailLogsRequest, opts ...grpc.CallOption) (ControlPlane_TailLogsClient, error)
+}
+
+type controlPlaneClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewControlPlaneClient(cc grpc.ClientConnInterface) ControlPlaneClient {
+	return &controlPlaneClient{cc}
+}
+
+func (c *controlPlaneClient) CreateFunction(ctx context.Context, opts ...grpc.CallOption) (ControlPlane_CreateFunctionClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ControlPlane_ServiceDesc.Streams[0], "/lambda.ControlPlane/CreateFunction", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &controlPlaneCreateFunctionClient{stream}
+	return x, nil
+}
+
+type ControlPlane_CreateFunctionClient interface {
+	Send(*UploadFunctionRequest) error
+	CloseAndRecv() (*Function, error)
+	grpc.ClientStream
+}
+
+type controlPlaneCreateFunctionClient struct {
+	grpc.ClientStream
+}
+
+func (x *controlPlaneCreateFunctionClient) Send(m *UploadFunctionRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *controlPlaneCreateFunctionClient) CloseAndRecv() (*Function, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Function)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *controlPlaneClient) UpdateFunction(ctx context.Context, opts ...grpc.CallOption) (ControlPlane_UpdateFunctionClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ControlPlane_ServiceDesc.Streams[1], "/lambda.ControlPlane/UpdateFunction", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &controlPlaneUpdateFunctionClient{stream}
+	return x, nil
+}
+
+type ControlPlane_UpdateFunctionClient interface {
+	Send(*UploadFunctionRequest) error
+	CloseAndRecv() (*Function, error)
+	grpc.ClientStream
+}
+
+type controlPlaneUpdateFunctionClient struct {
+	grpc.ClientStream
+}
+
+func (x *controlPlaneUpdateFunctionClient) Send(m *UploadFunctionRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *controlPlaneUpdateFunctionClient) CloseAndRecv() (*Function, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Function)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *controlPlaneClient) DeleteFunction(ctx context.Context, in *DeleteFunctionRequest, opts ...grpc.CallOption) (*DeleteFunctionResponse, error) {
+	out := new(DeleteFunctionResponse)
+	err := c.cc.Invoke(ctx, "/lambda.ControlPlane/DeleteFunction", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlPlaneClient) ListFunctions(ctx context.Context, in *ListFunctionsRequest, opts ...grpc.CallOption) (*ListFunctionsResponse, error) {
+	out := new(ListFunctionsResponse)
+	err := c.cc.Invoke(ctx, "/lambda.ControlPlane/ListFunctions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlPlaneClient) Invoke(ctx context.Context, in *InvokeRequest, opts ...grpc.CallOption) (*InvokeResponse, error) {
+	out := new(InvokeResponse)
+	err := c.cc.Invoke(ctx, "/lambda.ControlPlane/Invoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlPlaneClient) InvokeStream(ctx context.Context, in *InvokeRequest, opts ...grpc.CallOption) (ControlPlane_InvokeStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ControlPlane_ServiceDesc.Streams[2], "/lambda.ControlPlane/InvokeStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &controlPlaneInvokeStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ControlPlane_InvokeStreamClient interface {
+	Recv() (*InvokeResponse, error)
+	grpc.ClientStream
+}
+
+type controlPlaneInvokeStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *controlPlaneInvokeStreamClient) Recv() (*InvokeResponse, error) {
+	m := new(InvokeResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *controlPlaneClient) TailLogs(ctx context.Context, in *TailLogsRequest, opts ...grpc.CallOption) (ControlPlane_TailLogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ControlPlane_ServiceDesc.Streams[3], "/lambda.ControlPlane/TailLogs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &controlPlaneTailLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ControlPlane_TailLogsClient interface {
+	Recv() (*LogEntry, error)
+	grpc.ClientStream
+}
+
+type controlPlaneTailLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *controlPlaneTailLogsClient) Recv() (*LogEntry, error) {
+	m := new(LogEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ControlPlaneServer is the server API for ControlPlane service.
+// All implementations must embed UnimplementedControlPlaneServer
+// for forward compatibility
+type ControlPlaneServer interface {
+	// CreateFunction builds the function from the uploaded tar.gz archive of its sources.
+	// The first message is the spec, archive chunks follow.
+	CreateFunction(ControlPlane_CreateFunctionServer) error
+	// UpdateFunction builds the new version of the function and swaps it with the running one.
+	UpdateFunction(ControlPlane_UpdateFunctionServer) error
+	DeleteFunction(context.Context, *DeleteFunctionRequest) (*DeleteFunctionResponse, error)
+	ListFunctions(context.Context, *ListFunctionsRequest) (*ListFunctionsResponse, error)
+	Invoke(context.Context, *InvokeRequest) (*InvokeResponse, error)
+	// InvokeStream returns the function response as it is streamed.
+	InvokeStream(*InvokeRequest, ControlPlane_InvokeStreamServer) error
+	// TailLogs returns captured function logs and then new entries until canceled.
+	TailLogs(*TailLogsRequest, ControlPlane_TailLogsServer) error
+	mustEmbedUnimplementedControlPlaneServer()
+}
+
+// UnimplementedControlPlaneServer must be embedded to have forward compatible implementations.
+type UnimplementedControlPlaneServer struct {
+}
+
+func (UnimplementedControlPlaneServer) CreateFunction(ControlPlane_CreateFunctionServer) error {
+	return status.Errorf(codes.Unimplemented, "method CreateFunction not implemented")
+}
+func (UnimplementedControlPlaneServer) UpdateFunction(ControlPlane_UpdateFunctionServer) error {
+	return status.Errorf(codes.Unimplemented, "method UpdateFunction not implemented")
+}
+func (UnimplementedControlPlaneServer) DeleteFunction(context.Context, *DeleteFunctionRequest) (*DeleteFunctionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteFunction not implemented")
+}
+func (UnimplementedControlPlaneServer) ListFunctions(context.Context, *ListFunctionsRequest) (*ListFunctionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFunctions not implemented")
+}
+func (UnimplementedControlPlaneServer) Invoke(context.Context, *InvokeRequest) (*InvokeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Invoke not implemented")
+}
+func (UnimplementedControlPlaneServer) InvokeStream(*InvokeRequest, ControlPlane_InvokeStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method InvokeStream not implemented")
+}
+func (UnimplementedControlPlaneServer) TailLogs(*TailLogsRequest, ControlPlane_TailLogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method TailLogs not implemented")
+}
+func (UnimplementedControlPlaneServer) mustEmbedUnimplementedControlPlaneServer() {}
+
+// UnsafeControlPlaneServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ControlPlaneServer will
+// result in compilation errors.
+type UnsafeControlPlaneServer interface {
+	mustEmbedUnimplementedControlPlaneServer()
+}
+
+func RegisterControlPlaneServer(s grpc.ServiceRegistrar, srv ControlPlaneServer) {
+	s.RegisterService(&ControlPlane_ServiceDesc, srv)
+}
+
+func _ControlPlane_CreateFunction_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ControlPlaneServer).CreateFunction(&controlPlaneCreateFunctionServer{stream})
+}
+
+type ControlPlane_CreateFunctionServer interface {
+	SendAndClose(*Function) error
+	Recv() (*UploadFunctionRequest, error)
+	grpc.ServerStream
+}
+
+type controlPlaneCreateFunctionServer struct {
+	grpc.ServerStream
+}
+
+func (x *controlPlaneCreateFunctionServer) SendAndClose(m *Function) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *controlPlaneCreateFunctionServer) Recv() (*UploadFunctionRequest, error) {
+	m := new(UploadFunctionRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _ControlPlane_UpdateFunction_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ControlPlaneServer).UpdateFunction(&controlPlaneUpdateFunctionServer{stream})
+}
+
+type ControlPlane_UpdateFunctionServer interface {
+	SendAndClose(*Function) error
+	Recv() (*UploadFunctionRequest, error)
+	grpc.ServerStream
+}
+
+type controlPlaneUpdateFunctionServer struct {
+	grpc.ServerStream
+}
+
+func (x *controlPlaneUpdateFunctionServer) SendAndClose(m *Function) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *controlPlaneUpdateFunctionServer) Recv() (*UploadFunctionRequest, error) {
+	m := new(UploadFunctionRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _ControlPlane_DeleteFunction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteFunctionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlPlaneServer).DeleteFunction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lambda.ControlPlane/DeleteFunction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlPlaneServer).DeleteFunction(ctx, req.(*DeleteFunctionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControlPlane_ListFunctions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFunctionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlPlaneServer).ListFunctions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lambda.ControlPlane/ListFunctions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlPlaneServer).ListFunctions(ctx, req.(*ListFunctionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControlPlane_Invoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InvokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlPlaneServer).Invoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lambda.ControlPlane/Invoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlPlaneServer).Invoke(ctx, req.(*InvokeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControlPlane_InvokeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(InvokeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ControlPlaneServer).InvokeStream(m, &controlPlaneInvokeStreamServer{stream})
+}
+
+type ControlPlane_InvokeStreamServer interface {
+	Send(*InvokeResponse) error
+	grpc.ServerStream
+}
+
+type controlPlaneInvokeStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *controlPlaneInvokeStreamServer) Send(m *InvokeResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ControlPlane_TailLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TailLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ControlPlaneServer).TailLogs(m, &controlPlaneTailLogsServer{stream})
+}
+
+type ControlPlane_TailLogsServer interface {
+	Send(*LogEntry) error
+	grpc.ServerStream
+}
+
+type controlPlaneTailLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *controlPlaneTailLogsServer) Send(m *LogEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// ControlPlane_ServiceDesc is the grpc.ServiceDesc for ControlPlane service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ControlPlane_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "lambda.ControlPlane",
+	HandlerType: (*ControlPlaneServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "DeleteFunction",
+			Handler:    _ControlPlane_DeleteFunction_Handler,
+		},
+		{
+			MethodName: "ListFunctions",
+			Handler:    _ControlPlane_ListFunctions_Handler,
+		},
+		{
+			MethodName: "Invoke",
+			Handler:    _ControlPlane_Invoke_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "CreateFunction",
+			Handler:       _ControlPlane_CreateFunction_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "UpdateFunction",
+			Handler:       _ControlPlane_UpdateFunction_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "InvokeStream",
+			Handler:       _ControlPlane_InvokeStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "TailLogs",
+			Handler:       _ControlPlane_TailLogs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "control.proto",
+}
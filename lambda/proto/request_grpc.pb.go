@@ -23,6 +23,7 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type LambdaServerClient interface {
 	MakeRequest(ctx context.Context, in *Payload, opts ...grpc.CallOption) (*Payload, error)
+	StreamRequest(ctx context.Context, in *Payload, opts ...grpc.CallOption) (LambdaServer_StreamRequestClient, error)
 }
 
 type lambdaServerClient struct {
@@ -42,11 +43,44 @@ func (c *lambdaServerClient) MakeRequest(ctx context.Context, in *Payload, opts
 	return out, nil
 }
 
+func (c *lambdaServerClient) StreamRequest(ctx context.Context, in *Payload, opts ...grpc.CallOption) (LambdaServer_StreamRequestClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LambdaServer_ServiceDesc.Streams[0], "/lambda.LambdaServer/StreamRequest", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &lambdaServerStreamRequestClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LambdaServer_StreamRequestClient interface {
+	Recv() (*Payload, error)
+	grpc.ClientStream
+}
+
+type lambdaServerStreamRequestClient struct {
+	grpc.ClientStream
+}
+
+func (x *lambdaServerStreamRequestClient) Recv() (*Payload, error) {
+	m := new(Payload)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // LambdaServerServer is the server API for LambdaServer service.
 // All implementations must embed UnimplementedLambdaServerServer
 // for forward compatibility
 type LambdaServerServer interface {
 	MakeRequest(context.Context, *Payload) (*Payload, error)
+	StreamRequest(*Payload, LambdaServer_StreamRequestServer) error
 	mustEmbedUnimplementedLambdaServerServer()
 }
 
@@ -57,6 +91,9 @@ type UnimplementedLambdaServerServer struct {
 func (UnimplementedLambdaServerServer) MakeRequest(context.Context, *Payload) (*Payload, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method MakeRequest not implemented")
 }
+func (UnimplementedLambdaServerServer) StreamRequest(*Payload, LambdaServer_StreamRequestServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamRequest not implemented")
+}
 func (UnimplementedLambdaServerServer) mustEmbedUnimplementedLambdaServerServer() {}
 
 // UnsafeLambdaServerServer may be embedded to opt out of forward compatibility for this service.
@@ -88,6 +125,27 @@ func _LambdaServer_MakeRequest_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _LambdaServer_StreamRequest_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Payload)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LambdaServerServer).StreamRequest(m, &lambdaServerStreamRequestServer{stream})
+}
+
+type LambdaServer_StreamRequestServer interface {
+	Send(*Payload) error
+	grpc.ServerStream
+}
+
+type lambdaServerStreamRequestServer struct {
+	grpc.ServerStream
+}
+
+func (x *lambdaServerStreamRequestServer) Send(m *Payload) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // LambdaServer_ServiceDesc is the grpc.ServiceDesc for LambdaServer service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -100,6 +158,12 @@ var LambdaServer_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _LambdaServer_MakeRequest_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamRequest",
+			Handler:       _LambdaServer_StreamRequest_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "request.proto",
 }
@@ -0,0 +1,96 @@
+package proto
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"google.golang.org/grpc"
+)
+
+// UploadChunkSize is the size of the archive chunks sent by CreateFunction and UpdateFunction.
+const UploadChunkSize = 64 << 10
+
+// uploadClient is the client side of ControlPlane upload calls.
+type uploadClient interface {
+	Send(*UploadFunctionRequest) error
+	CloseAndRecv() (*Function, error)
+}
+
+// CreateFunction uploads tar.gz archive of the function sources and returns the built function.
+func CreateFunction(
+	ctx context.Context,
+	client ControlPlaneClient,
+	spec *FunctionSpec,
+	archive io.Reader,
+	opts ...grpc.CallOption,
+) (*Function, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.CreateFunction(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return upload(stream, spec, archive)
+}
+
+// UpdateFunction uploads tar.gz archive of the new function version and returns the function after the swap.
+func UpdateFunction(
+	ctx context.Context,
+	client ControlPlaneClient,
+	spec *FunctionSpec,
+	archive io.Reader,
+	opts ...grpc.CallOption,
+) (*Function, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.UpdateFunction(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return upload(stream, spec, archive)
+}
+
+// upload sends the spec and the archive chunks, the call is canceled by the caller on the read error.
+func upload(stream uploadClient, spec *FunctionSpec, archive io.Reader) (*Function, error) {
+	// Send returns io.EOF if the server has finished the call, its status is returned by CloseAndRecv.
+	if err := stream.Send(&UploadFunctionRequest{Payload: &UploadFunctionRequest_Spec{Spec: spec}}); err != nil {
+		if errors.Is(err, io.EOF) {
+			return stream.CloseAndRecv()
+		}
+
+		return nil, err
+	}
+
+	buf := make([]byte, UploadChunkSize)
+
+	for {
+		n, err := archive.Read(buf)
+
+		if n > 0 {
+			sendErr := stream.Send(&UploadFunctionRequest{Payload: &UploadFunctionRequest_Chunk{Chunk: buf[:n]}})
+
+			if errors.Is(sendErr, io.EOF) {
+				return stream.CloseAndRecv()
+			}
+
+			if sendErr != nil {
+				return nil, sendErr
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return stream.CloseAndRecv()
+}
@@ -1,53 +1,206 @@
 package lambda
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"log/slog"
+	"io"
 	"net"
+	"os"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 
+	"github.com/ihippik/lambda-go/builder"
 	"github.com/ihippik/lambda-go/lambda/proto"
 )
 
+// maxChunkSize is a maximum size of the single streamed message.
+const maxChunkSize = 1 << 20
+
 // Handler is a user function that handles lambda requests.
 type Handler func(ctx context.Context, payload []byte) ([]byte, error)
 
+// StreamHandler is a user function that writes the response progressively.
+type StreamHandler func(ctx context.Context, payload []byte, w io.Writer) error
+
 // Server is a wrapper for user Handler.
 type Server struct {
 	proto.UnimplementedLambdaServerServer
-	handler Handler
+	handler       Handler
+	streamHandler StreamHandler
+}
+
+// NewServer returns the server of the lambda handler to register on gRPC server.
+// Start should be used in the function container, it is for serving the handler in-process, e.g. in tests.
+func NewServer(handler Handler) *Server {
+	return &Server{handler: handler}
+}
+
+// NewStreamingServer returns the server of the lambda handler which streams its response.
+func NewStreamingServer(handler StreamHandler) *Server {
+	return &Server{streamHandler: handler}
+}
+
+// Register registers the server on gRPC server.
+func (h *Server) Register(registrar grpc.ServiceRegistrar) {
+	proto.RegisterLambdaServerServer(registrar, h)
 }
 
 // Start starts the lambda handler.
+// Records of Logger are tagged with the invocation ID, the default slog logger is not changed and its records are not.
 func Start(handler Handler) {
-	const serverAddr = ":8080"
+	serve(NewServer(handler))
+}
+
+// StartStreaming starts the lambda handler which streams its response.
+func StartStreaming(handler StreamHandler) {
+	serve(NewStreamingServer(handler))
+}
+
+// serve starts gRPC server for the lambda handler.
+func serve(srv *Server) {
+	// function container listens on the fixed port, process runtime passes the assigned address.
+	serverAddr := os.Getenv(builder.EnvListenAddr)
+	if serverAddr == "" {
+		serverAddr = ":" + builder.FunctionPort
+	}
 
 	lis, err := net.Listen("tcp", serverAddr)
 	if err != nil {
-		slog.Error(err.Error())
+		logger.Error(err.Error())
 	}
 	defer lis.Close()
 
-	grpcServer := grpc.NewServer()
+	var opts []grpc.ServerOption
 
-	proto.RegisterLambdaServerServer(grpcServer, &Server{handler: handler})
+	tlsConfig, err := functionTLSConfig()
+	if err != nil {
+		logger.Error("tls config", "err", err.Error())
+		return
+	}
+
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	grpcServer := grpc.NewServer(opts...)
+
+	srv.Register(grpcServer)
 
 	if err := grpcServer.Serve(lis); err != nil {
-		slog.Error(err.Error())
+		logger.Error(err.Error())
 	}
 }
 
-func (h *Server) MakeRequest(ctx context.Context, payload *proto.Payload) (*proto.Payload, error) {
-	slog.Debug("got request", "payload_size", len(payload.Data))
+func (h *Server) MakeRequest(ctx context.Context, payload *proto.Payload) (_ *proto.Payload, err error) {
+	ctx, done := h.begin(ctx, payload)
+	defer func() { done(err) }()
 
-	respData, err := h.handler(ctx, payload.Data)
+	logger.DebugContext(ctx, "got request", "payload_size", len(payload.Data))
+
+	respData, err := h.call(ctx, payload.Data)
 	if err != nil {
-		return nil, fmt.Errorf("handler: %w", err)
+		return nil, handlerError(err)
 	}
 
-	slog.Debug("got response", "payload_size", "response_size", len(respData))
+	logger.DebugContext(ctx, "got response", "response_size", len(respData))
 
 	return &proto.Payload{Data: respData}, nil
 }
+
+// StreamRequest calls handler and sends its output to the client as soon as it was written.
+func (h *Server) StreamRequest(payload *proto.Payload, stream proto.LambdaServer_StreamRequestServer) (err error) {
+	ctx, done := h.begin(stream.Context(), payload)
+	defer func() { done(err) }()
+
+	logger.DebugContext(ctx, "got stream request", "payload_size", len(payload.Data))
+
+	if h.streamHandler == nil {
+		respData, err := h.handler(ctx, payload.Data)
+		if err != nil {
+			return handlerError(err)
+		}
+
+		_, err = (&streamWriter{stream: stream}).Write(respData)
+
+		return err
+	}
+
+	if err := h.streamHandler(ctx, payload.Data, &streamWriter{stream: stream}); err != nil {
+		return handlerError(err)
+	}
+
+	return nil
+}
+
+// handlerError returns gRPC error of the handler, status errors are returned as is to keep their code and message.
+func handlerError(err error) error {
+	if _, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		return err
+	}
+
+	return fmt.Errorf("handler: %w", err)
+}
+
+// begin puts invocation ID into the context, starts handler span as a child of the control plane span
+// and marks invocation as in flight until done is called.
+func (h *Server) begin(ctx context.Context, payload *proto.Payload) (context.Context, func(error)) {
+	ctx = propagator.Extract(ctx, propagation.MapCarrier(payload.Metadata))
+	ctx = withClaims(ctx, payload.Metadata)
+	ctx, span := tracer.Start(ctx, "handler", trace.WithSpanKind(trace.SpanKindServer))
+
+	id := payload.InvocationId
+	if id == "" {
+		return ctx, func(err error) { endSpan(span, err) }
+	}
+
+	span.SetAttributes(attribute.String("faas.invocation_id", id))
+	invocations.add(id)
+
+	return withInvocationID(ctx, id), func(err error) {
+		invocations.remove(id)
+		endSpan(span, err)
+	}
+}
+
+// call calls the handler and returns the whole response.
+func (h *Server) call(ctx context.Context, data []byte) ([]byte, error) {
+	if h.streamHandler == nil {
+		return h.handler(ctx, data)
+	}
+
+	var buf bytes.Buffer
+
+	if err := h.streamHandler(ctx, data, &buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// streamWriter sends written data to the gRPC stream.
+type streamWriter struct {
+	stream proto.LambdaServer_StreamRequestServer
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	var written int
+
+	for len(p) > 0 {
+		size := min(len(p), maxChunkSize)
+
+		if err := w.stream.Send(&proto.Payload{Data: p[:size]}); err != nil {
+			return written, fmt.Errorf("send: %w", err)
+		}
+
+		written += size
+		p = p[size:]
+	}
+
+	return written, nil
+}
@@ -0,0 +1,463 @@
+package lambda
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// Route maps HTTP method and path template to the lambda function.
+// Function is referenced as "namespace/name" or "name" of the default namespace.
+type Route struct {
+	ID       string      `json:"id"`
+	Method   string      `json:"method"`
+	Path     string      `json:"path"`
+	Function string      `json:"function"`
+	CORS     *CORSConfig `json:"cors,omitempty"`
+}
+
+// CORSConfig is a per-route CORS settings.
+type CORSConfig struct {
+	AllowOrigins     []string `json:"allow_origins"`
+	AllowMethods     []string `json:"allow_methods,omitempty"`
+	AllowHeaders     []string `json:"allow_headers,omitempty"`
+	AllowCredentials bool     `json:"allow_credentials,omitempty"`
+	MaxAge           int      `json:"max_age,omitempty"`
+}
+
+// HTTPEvent is a payload which the function receives when it was invoked via custom route.
+type HTTPEvent struct {
+	Method          string              `json:"method"`
+	Path            string              `json:"path"`
+	PathParameters  map[string]string   `json:"path_parameters"`
+	QueryParameters map[string][]string `json:"query_parameters"`
+	Headers         map[string][]string `json:"headers"`
+	Body            string              `json:"body"`
+}
+
+// reservedPrefixes are the path prefixes used by the built-in endpoints.
+var reservedPrefixes = []string{"/lambda/", "/routes", "/connections/", "/metrics", "/invocations/", "/usage", "/ns/", "/keys", "/audit"}
+
+// validate checks that route could be registered in the router.
+func (r *Route) validate() error {
+	r.Method = strings.ToUpper(r.Method)
+
+	if r.Method == "" || r.Method == http.MethodOptions {
+		return errors.New("invalid method")
+	}
+
+	if !strings.HasPrefix(r.Path, "/") {
+		return errors.New("path must start with /")
+	}
+
+	for _, prefix := range reservedPrefixes {
+		// prefix of the nested paths is reserved as the exact path as well, e.g. "/lambda".
+		if strings.HasPrefix(r.Path, prefix) || r.Path == strings.TrimSuffix(prefix, "/") {
+			return fmt.Errorf("path prefix %s is reserved", prefix)
+		}
+	}
+
+	if r.Function == "" {
+		return errors.New("function is required")
+	}
+
+	name, err := parseFunction(r.Function)
+	if err != nil {
+		return err
+	}
+
+	r.Function = name
+
+	if err := mux.NewRouter().NewRoute().Path(r.Path).GetError(); err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+
+	return nil
+}
+
+// allowOrigin returns the value of Access-Control-Allow-Origin header for the request origin.
+func (c *CORSConfig) allowOrigin(origin string) string {
+	for _, v := range c.AllowOrigins {
+		if v == "*" && !c.AllowCredentials {
+			return "*"
+		}
+
+		if v == "*" || v == origin {
+			return origin
+		}
+	}
+
+	return ""
+}
+
+// setHeaders sets CORS headers to the response. Returns false if origin is not allowed.
+func (c *CORSConfig) setHeaders(w http.ResponseWriter, r *http.Request, preflight bool) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+
+	allowed := c.allowOrigin(origin)
+	if allowed == "" {
+		return false
+	}
+
+	h := w.Header()
+	h.Set("Access-Control-Allow-Origin", allowed)
+	h.Add("Vary", "Origin")
+
+	if c.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		return true
+	}
+
+	if len(c.AllowMethods) > 0 {
+		h.Set("Access-Control-Allow-Methods", strings.Join(c.AllowMethods, ", "))
+	} else {
+		h.Set("Access-Control-Allow-Methods", r.Header.Get("Access-Control-Request-Method"))
+	}
+
+	if len(c.AllowHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(c.AllowHeaders, ", "))
+	} else if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+		h.Set("Access-Control-Allow-Headers", reqHeaders)
+	}
+
+	if c.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(c.MaxAge))
+	}
+
+	return true
+}
+
+// errRouteExists is returned when the route with the same method and path is registered.
+var errRouteExists = errors.New("route already exists")
+
+// routeTable is a thread-safe storage for custom routes persisted in the JSON file.
+type routeTable struct {
+	mu     sync.RWMutex
+	path   string
+	routes map[string]Route
+}
+
+// newRouteTable loads routes from the file, missing file means no routes.
+func newRouteTable(path string) (*routeTable, error) {
+	t := &routeTable{path: path, routes: make(map[string]Route)}
+
+	var routes []Route
+
+	if err := readJSONFile(path, &routes); err != nil {
+		return nil, err
+	}
+
+	for _, r := range routes {
+		t.routes[r.ID] = r
+	}
+
+	return t, nil
+}
+
+// save writes routes to the file atomically. Must be called with the lock held.
+func (t *routeTable) save() error {
+	routes := make([]Route, 0, len(t.routes))
+	for _, r := range t.routes {
+		routes = append(routes, r)
+	}
+
+	sortRoutes(routes)
+
+	return writeJSONFile(t.path, routes)
+}
+
+// list returns all routes sorted by path and method.
+func (t *routeTable) list() []Route {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	routes := make([]Route, 0, len(t.routes))
+	for _, r := range t.routes {
+		routes = append(routes, r)
+	}
+
+	sortRoutes(routes)
+
+	return routes
+}
+
+// sortRoutes sorts routes by path and method.
+func sortRoutes(routes []Route) {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+
+		return routes[i].Path < routes[j].Path
+	})
+}
+
+// put adds or replaces the route. Method and path pair must be unique.
+func (t *routeTable) put(route Route) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, r := range t.routes {
+		if id != route.ID && r.Method == route.Method && r.Path == route.Path {
+			return fmt.Errorf("%w: %s %s", errRouteExists, r.Method, r.Path)
+		}
+	}
+
+	before, ok := t.routes[route.ID]
+	t.routes[route.ID] = route
+
+	if err := t.save(); err != nil {
+		if ok {
+			t.routes[route.ID] = before
+		} else {
+			delete(t.routes, route.ID)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// get returns route by its ID.
+func (t *routeTable) get(id string) (Route, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	r, ok := t.routes[id]
+
+	return r, ok
+}
+
+// delete removes route by its ID.
+func (t *routeTable) delete(id string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.routes[id]
+	if !ok {
+		return false, nil
+	}
+
+	delete(t.routes, id)
+
+	if err := t.save(); err != nil {
+		t.routes[id] = r
+		return false, err
+	}
+
+	return true, nil
+}
+
+// newID returns random identifier.
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// listRoutes http endpoint for list custom routes.
+func (e *Endpoint) listRoutes(w http.ResponseWriter, _ *http.Request) {
+	e.writeJSON(w, http.StatusOK, e.routes.list())
+}
+
+// createRoute http endpoint for create custom route.
+func (e *Endpoint) createRoute(w http.ResponseWriter, r *http.Request) {
+	var route Route
+
+	if err := json.NewDecoder(r.Body).Decode(&route); err != nil {
+		e.writeAudit(r, AuditRouteCreate, "", nil, nil, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	route.ID = newID()
+
+	e.saveRoute(w, r, AuditRouteCreate, nil, route, http.StatusCreated)
+}
+
+// updateRoute http endpoint for replace custom route.
+func (e *Endpoint) updateRoute(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	before, ok := e.routes.get(id)
+	if !ok {
+		http.Error(w, "route not found", http.StatusNotFound)
+		return
+	}
+
+	var route Route
+
+	if err := json.NewDecoder(r.Body).Decode(&route); err != nil {
+		e.writeAudit(r, AuditRouteUpdate, id, before, before, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	route.ID = id
+
+	e.saveRoute(w, r, AuditRouteUpdate, &before, route, http.StatusOK)
+}
+
+// saveRoute validates and stores the route, then rebuilds the router.
+// Before is the replaced route or nil for the new one.
+func (e *Endpoint) saveRoute(w http.ResponseWriter, r *http.Request, action string, before *Route, route Route, status int) {
+	if err := route.validate(); err != nil {
+		e.writeAudit(r, action, route.ID, before, before, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := e.routes.put(route); err != nil {
+		e.writeAudit(r, action, route.ID, before, before, err)
+
+		if errors.Is(err, errRouteExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		e.logger.Error("route: save error", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	e.rebuildRouter()
+	e.writeAudit(r, action, route.ID, before, route, nil)
+
+	e.logger.Info(
+		"route saved",
+		slog.String("id", route.ID),
+		slog.String("method", route.Method),
+		slog.String("path", route.Path),
+		slog.String("func_name", route.Function),
+	)
+
+	e.writeJSON(w, status, route)
+}
+
+// deleteRoute http endpoint for delete custom route.
+func (e *Endpoint) deleteRoute(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	before, ok := e.routes.get(id)
+	if !ok {
+		http.Error(w, "route not found", http.StatusNotFound)
+		return
+	}
+
+	deleted, err := e.routes.delete(id)
+	if err != nil {
+		e.writeAudit(r, AuditRouteDelete, id, before, before, err)
+		e.logger.Error("route: save error", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if !deleted {
+		http.Error(w, "route not found", http.StatusNotFound)
+		return
+	}
+
+	e.rebuildRouter()
+	e.writeAudit(r, AuditRouteDelete, id, before, nil, nil)
+
+	e.logger.Info("route deleted", slog.String("id", id))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// routeHandler returns handler which invokes the route function with HTTPEvent payload.
+func (e *Endpoint) routeHandler(route Route) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			e.logger.Error("route: read body error", "err", err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		event := HTTPEvent{
+			Method:          r.Method,
+			Path:            r.URL.Path,
+			PathParameters:  mux.Vars(r),
+			QueryParameters: r.URL.Query(),
+			Headers:         r.Header,
+			Body:            string(body),
+		}
+
+		data, err := json.Marshal(event)
+		if err != nil {
+			e.logger.Error("route: marshal event error", "err", err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if route.CORS != nil {
+			route.CORS.setHeaders(w, r, false)
+		}
+
+		e.logger.Info("got route request", slog.String("route", route.ID), slog.String("func_name", route.Function))
+
+		respData, err := e.svc.Invoke(r.Context(), route.Function, data)
+		if err != nil {
+			e.logger.Error("route: invoke service error", "err", err.Error())
+
+			status := http.StatusBadGateway
+			if errors.Is(err, ErrThrottled) || errors.Is(err, ErrQuotaExceeded) {
+				status = http.StatusTooManyRequests
+			}
+
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		if _, err := w.Write(respData); err != nil {
+			e.logger.Error("route: write error", "err", err.Error())
+		}
+	}
+}
+
+// preflightHandler returns handler for CORS preflight requests of the routes with the same path.
+func (e *Endpoint) preflightHandler(routes []Route) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		method := r.Header.Get("Access-Control-Request-Method")
+
+		for _, route := range routes {
+			if route.Method != method {
+				continue
+			}
+
+			if !route.CORS.setHeaders(w, r, true) {
+				break
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+
+			return
+		}
+
+		http.Error(w, "CORS request not allowed", http.StatusForbidden)
+	}
+}
@@ -0,0 +1,75 @@
+package lambda
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRouteTablePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.json")
+
+	table, err := newRouteTable(path)
+	if err != nil {
+		t.Fatalf("new route table: %v", err)
+	}
+
+	users := Route{ID: "users", Method: "GET", Path: "/users/{id}", Function: "default/users"}
+	orders := Route{ID: "orders", Method: "POST", Path: "/orders", Function: "shop/orders"}
+
+	for _, r := range []Route{users, orders} {
+		if err := table.put(r); err != nil {
+			t.Fatalf("put %s: %v", r.ID, err)
+		}
+	}
+
+	duplicate := Route{ID: "other", Method: "GET", Path: "/users/{id}", Function: "default/other"}
+	if err := table.put(duplicate); !errors.Is(err, errRouteExists) {
+		t.Fatalf("expected route exists error, got %v", err)
+	}
+
+	if deleted, err := table.delete(users.ID); err != nil || !deleted {
+		t.Fatalf("delete: %v %v", deleted, err)
+	}
+
+	reloaded, err := newRouteTable(path)
+	if err != nil {
+		t.Fatalf("reload route table: %v", err)
+	}
+
+	routes := reloaded.list()
+	if len(routes) != 1 || routes[0] != orders {
+		t.Fatalf("unexpected routes after reload %+v", routes)
+	}
+}
+
+func TestRouteValidateReserved(t *testing.T) {
+	tests := []struct {
+		path     string
+		reserved bool
+	}{
+		{path: "/lambda", reserved: true},
+		{path: "/lambda/hello/invoke", reserved: true},
+		{path: "/ns", reserved: true},
+		{path: "/ns/team-a/lambda/hello", reserved: true},
+		{path: "/connections", reserved: true},
+		{path: "/invocations", reserved: true},
+		{path: "/routes", reserved: true},
+		{path: "/keys/{id}", reserved: true},
+		{path: "/lambdas", reserved: false},
+		{path: "/news", reserved: false},
+		{path: "/users/{id}", reserved: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			r := Route{Method: "GET", Path: tt.path, Function: "hello"}
+
+			err := r.validate()
+			if reserved := err != nil && strings.Contains(err.Error(), "is reserved"); reserved != tt.reserved {
+				t.Fatalf("validate: %v, reserved %v", err, tt.reserved)
+			}
+		})
+	}
+}
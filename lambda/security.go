@@ -0,0 +1,126 @@
+package lambda
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ihippik/lambda-go/builder"
+	"github.com/ihippik/lambda-go/config"
+)
+
+// Parts of the hardening profile which could be disabled for the function.
+const (
+	OptOutUser            = "user"
+	OptOutReadOnly        = "read_only"
+	OptOutCapDrop         = "cap_drop"
+	OptOutNoNewPrivileges = "no_new_privileges"
+	OptOutSeccomp         = "seccomp"
+)
+
+var knownOptOuts = []string{OptOutUser, OptOutReadOnly, OptOutCapDrop, OptOutNoNewPrivileges, OptOutSeccomp}
+
+// CreateOptions are the per-function options of the container.
+type CreateOptions struct {
+	// OptOut disables parts of the hardening profile for the function.
+	OptOut []string `json:"security_opt_out,omitempty"`
+	// NoEgress attaches the function to the internal network without access outside of the host.
+	NoEgress bool `json:"no_egress,omitempty"`
+	// Egress is the allow-list of destinations (CIDR or host:port) reachable through the egress proxy.
+	Egress []string `json:"egress,omitempty"`
+}
+
+// validate checks opt-outs and egress destinations and sorts them.
+func (o *CreateOptions) validate() error {
+	for _, opt := range o.OptOut {
+		if !slices.Contains(knownOptOuts, opt) {
+			return fmt.Errorf("unknown security opt-out %q", opt)
+		}
+	}
+
+	if _, err := parseEgressRules(o.Egress); err != nil {
+		return err
+	}
+
+	if o.NoEgress && len(o.Egress) > 0 {
+		return errors.New("no egress and egress allow-list are mutually exclusive")
+	}
+
+	sort.Strings(o.OptOut)
+	o.OptOut = slices.Compact(o.OptOut)
+
+	sort.Strings(o.Egress)
+	o.Egress = slices.Compact(o.Egress)
+
+	return nil
+}
+
+// parseList parses comma-separated values, e.g. opt-outs or egress destinations.
+func parseList(v string) []string {
+	var list []string
+
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+// hardeningProfile is the configured security profile of the function containers.
+type hardeningProfile struct {
+	cfg     config.SecurityCfg
+	seccomp string
+}
+
+func newHardeningProfile(cfg config.SecurityCfg) (*hardeningProfile, error) {
+	p := &hardeningProfile{cfg: cfg}
+
+	if cfg.SeccompProfile != "" {
+		data, err := os.ReadFile(cfg.SeccompProfile)
+		if err != nil {
+			return nil, fmt.Errorf("read seccomp profile: %w", err)
+		}
+
+		p.seccomp = string(data)
+	}
+
+	return p, nil
+}
+
+// hardening returns the container security profile without the opted out parts.
+func (p *hardeningProfile) hardening(optOut []string) builder.Hardening {
+	if !p.cfg.Enabled {
+		return builder.Hardening{}
+	}
+
+	h := builder.Hardening{
+		User:            p.cfg.User,
+		ReadOnlyRootfs:  true,
+		TmpfsSizeMB:     p.cfg.TmpfsSizeMB,
+		DropAllCaps:     true,
+		NoNewPrivileges: true,
+		Seccomp:         p.seccomp,
+	}
+
+	for _, opt := range optOut {
+		switch opt {
+		case OptOutUser:
+			h.User = ""
+		case OptOutReadOnly:
+			h.ReadOnlyRootfs = false
+		case OptOutCapDrop:
+			h.DropAllCaps = false
+		case OptOutNoNewPrivileges:
+			h.NoNewPrivileges = false
+		case OptOutSeccomp:
+			h.Seccomp = "unconfined"
+		}
+	}
+
+	return h
+}
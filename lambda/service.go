@@ -4,65 +4,163 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"math/rand"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/avast/retry-go"
-	"github.com/docker/docker/api/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"github.com/ihippik/lambda-go/builder"
 	"github.com/ihippik/lambda-go/config"
 	"github.com/ihippik/lambda-go/lambda/proto"
 )
 
-type builder interface {
-	ImageBuild(ctx context.Context, file string, name string) (string, error)
-	ContainerCreate(ctx context.Context, image string, port int) (string, error)
+// Runtime is the backend which builds and runs function containers, e.g. builder.Docker or builder.Process.
+type Runtime interface {
+	// ImageBuild builds the function from the sources in dir and returns the image to create containers from.
+	ImageBuild(ctx context.Context, dir string, name string) (string, error)
+	ContainerCreate(ctx context.Context, opts builder.ContainerOptions) (string, error)
 	ContainerStart(ctx context.Context, containerID string) error
 	ContainerStop(ctx context.Context, containerID string) error
-	ContainersList(ctx context.Context) ([]types.Container, error)
-	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
+	ContainerRemove(ctx context.Context, containerID string) error
+	ContainersList(ctx context.Context) ([]builder.Container, error)
+	ContainerInspect(ctx context.Context, containerID string) (builder.Container, error)
+	// ContainerLogs follows the container output until it is stopped or context is canceled.
+	ContainerLogs(ctx context.Context, containerID string, since time.Time, fn func(stream, line string)) error
+	NetworkEnsure(ctx context.Context, name string, isolated, internal bool) error
+	NetworkGateway(ctx context.Context, name string) (string, error)
 }
 
+// Runtime backends.
+const (
+	RuntimeDocker  = "docker"
+	RuntimeProcess = "process"
+)
+
+// Network modes of the function containers.
+const (
+	networkModeBridge   = "bridge"
+	networkModeHostPort = "host-port"
+)
+
 // Service is a service for lambda.
 type Service struct {
 	cfg      *config.Config
 	log      *slog.Logger
 	client   *http.Client
-	builder  builder
+	builder  Runtime
 	register sync.Map
+	// creating holds the names of functions being built by Create.
+	creating sync.Map
+	logs     *logStore
+	history  *historyStore
+	usage    *usageStore
+	ports    *portAllocator
+	profile  *hardeningProfile
+	egress   *egressTable
+
+	// ca and clientTLS are set if mTLS between control plane and functions is enabled.
+	ca        *certAuthority
+	clientTLS *tls.Config
 }
 
 // NewService returns new Service instance.
-func NewService(cfg *config.Config, log *slog.Logger, container builder) *Service {
-	return &Service{
+func NewService(cfg *config.Config, log *slog.Logger, runtime Runtime) (*Service, error) {
+	s := &Service{
 		cfg:     cfg,
 		log:     log,
-		builder: container,
+		builder: runtime,
 		client:  http.DefaultClient,
+		logs:    newLogStore(cfg.App.LogBufferSize, log),
+		history: newHistoryStore(cfg.App.HistorySize),
+		egress:  newEgressTable(),
+	}
+
+	usage, err := newUsageStore(cfg.App.UsageRetentionDays, cfg.App.QuotasFile)
+	if err != nil {
+		return nil, fmt.Errorf("new usage store: %w", err)
+	}
+
+	s.usage = usage
+
+	switch cfg.App.NetworkMode {
+	case networkModeBridge, networkModeHostPort:
+	default:
+		return nil, fmt.Errorf("unknown network mode %q", cfg.App.NetworkMode)
+	}
+
+	// function processes have no networks, they listen on the host ports.
+	if cfg.App.Runtime == RuntimeProcess && cfg.App.NetworkMode != networkModeHostPort {
+		return nil, errors.New("process runtime requires host-port network mode")
+	}
+
+	ports, err := newPortAllocator(cfg.App.PortRangeMin, cfg.App.PortRangeMax, cfg.App.FunctionHostIP)
+	if err != nil {
+		return nil, fmt.Errorf("new port allocator: %w", err)
+	}
+
+	s.ports = ports
+
+	if s.profile, err = newHardeningProfile(cfg.Security); err != nil {
+		return nil, fmt.Errorf("new hardening profile: %w", err)
+	}
+
+	if cfg.TLS.FunctionMTLS {
+		ca, err := loadOrCreateCA(cfg.TLS.CADir, cfg.TLS.CertValidity)
+		if err != nil {
+			return nil, fmt.Errorf("load CA: %w", err)
+		}
+
+		clientTLS, err := ca.clientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("issue client certificate: %w", err)
+		}
+
+		s.ca = ca
+		s.clientTLS = clientTLS
 	}
+
+	return s, nil
 }
 
 // Init initializes service.
-// It gets all containers with name "go-lambda" and registers them in the service.
+// It gets all function containers (labeled or legacy with name "go-lambda") and registers them in the service.
 func (s *Service) Init(ctx context.Context) error {
+	if s.cfg.App.NetworkMode == networkModeBridge {
+		// functions are isolated from each other unless the control plane dials them by name from the network.
+		if err := s.builder.NetworkEnsure(ctx, s.cfg.App.NetworkName, !s.cfg.App.NetworkDNS, false); err != nil {
+			return fmt.Errorf("ensure network: %w", err)
+		}
+
+		if err := s.builder.NetworkEnsure(ctx, s.internalNetwork(), !s.cfg.App.NetworkDNS, true); err != nil {
+			return fmt.Errorf("ensure internal network: %w", err)
+		}
+	}
+
 	containers, err := s.builder.ContainersList(ctx)
 	if err != nil {
 		return fmt.Errorf("list containers: %w", err)
 	}
 
 	for _, container := range containers {
-		if len(container.Names) > 0 && container.Names[0] != "/go-lambda" {
+		_, labeled := container.Labels[builder.LabelFunction]
+		legacy := container.Name == "go-lambda"
+
+		if !labeled && !legacy {
 			continue
 		}
 
@@ -76,7 +174,29 @@ func (s *Service) Init(ctx context.Context) error {
 			return fmt.Errorf("parse container data: %w", err)
 		}
 
-		s.register.Store(funcName, newMetaData(container.ID, port))
+		meta := newMetaData(container.ID, port)
+		meta.tls = container.Labels[builder.LabelTLS] == "true"
+		meta.network = container.Labels[builder.LabelNetwork]
+		meta.opts = containerOptions(data, port)
+		meta.create = CreateOptions{
+			OptOut: parseList(container.Labels[builder.LabelSecurityOptOut]),
+			Egress: parseList(container.Labels[builder.LabelEgress]),
+		}
+		meta.create.NoEgress = meta.network != "" && meta.network == s.internalNetwork() && len(meta.create.Egress) == 0
+
+		if len(meta.create.Egress) > 0 {
+			meta.egressToken = s.registerEgress(funcName, meta.create.Egress, data.Env)
+		}
+
+		if port != 0 {
+			s.ports.reserve(port, funcName)
+		}
+
+		if meta.network != "" && s.cfg.App.NetworkDNS {
+			meta.host = data.Name
+		}
+
+		s.register.Store(funcName, meta)
 
 		s.log.Info("init: register function", "name", funcName, "port", port)
 	}
@@ -84,108 +204,737 @@ func (s *Service) Init(ctx context.Context) error {
 	return nil
 }
 
-// Create creates new lambda function. If function with the same name already exists, it will skip.
-func (s *Service) Create(ctx context.Context, name string, file io.ReadCloser) error {
-	// TODO: if container exists, need to overwrite it
+// Create creates new lambda function, ErrFunctionExists is returned if it is registered or being created.
+// Name is the function registry key in "namespace/name" form.
+func (s *Service) Create(ctx context.Context, name string, file io.ReadCloser, createOpts CreateOptions) error {
+	if err := s.validateOptions(&createOpts); err != nil {
+		return err
+	}
+
+	// the name is reserved for the build, so the concurrent create of the same function fails.
+	if _, building := s.creating.LoadOrStore(name, struct{}{}); building {
+		return ErrFunctionExists
+	}
+	defer s.creating.Delete(name)
+
 	if _, exists := s.register.Load(name); exists {
-		s.log.Info("container already exists", slog.String("name", name))
-		return nil
+		return ErrFunctionExists
 	}
 
-	port := rand.Intn(65535-1024) + 1024
+	meta, err := s.build(ctx, name, containerName(name), file, createOpts)
+	if err != nil {
+		return err
+	}
+
+	if _, exists := s.register.LoadOrStore(name, meta); exists {
+		s.remove(ctx, meta)
+		return ErrFunctionExists
+	}
+
+	return nil
+}
+
+// Update builds the new version of the function and swaps it with the running one.
+// The function is served by the old container until the new one is created,
+// invocations in progress are finished by the old container which is removed then.
+func (s *Service) Update(ctx context.Context, name string, file io.ReadCloser, createOpts CreateOptions) error {
+	if err := s.validateOptions(&createOpts); err != nil {
+		return err
+	}
+
+	value, ok := s.register.Load(name)
+	if !ok {
+		return ErrFunctionNotFound
+	}
+
+	old, ok := value.(*metaData)
+	if !ok {
+		return errors.New("invalid container meta type")
+	}
 
-	if err := s.decompress("infra", file); err != nil {
-		return fmt.Errorf("decompress: %w", err)
+	// container names are unique, so the new container gets the suffix.
+	meta, err := s.build(ctx, name, containerName(name)+"-"+newID()[:8], file, createOpts)
+	if err != nil {
+		return err
+	}
+
+	if !s.register.CompareAndSwap(name, old, meta) {
+		s.remove(ctx, meta)
+		return fmt.Errorf("function %s was changed concurrently", name)
 	}
 
-	img, err := s.builder.ImageBuild(ctx, "infra", name)
+	old.mu.Lock()
+	defer old.mu.Unlock()
+
+	old.retired = true
+
+	if old.active == 0 {
+		s.remove(ctx, old)
+	}
+
+	s.log.Info("function updated", slog.String("name", name))
+
+	return nil
+}
+
+// validateOptions validates create options against the service configuration.
+func (s *Service) validateOptions(createOpts *CreateOptions) error {
+	if err := createOpts.validate(); err != nil {
+		return err
+	}
+
+	// there is no profile to weaken, so the opt-out would be audited without any effect.
+	if len(createOpts.OptOut) > 0 && !s.cfg.Security.Enabled {
+		return errors.New("security opt-out requires container hardening (SECURITY_HARDENING=true)")
+	}
+
+	if createOpts.NoEgress && s.cfg.App.NetworkMode != networkModeBridge {
+		return errors.New("no egress requires bridge network mode")
+	}
+
+	if len(createOpts.Egress) > 0 && (s.cfg.App.NetworkMode != networkModeBridge || s.cfg.App.EgressProxyAddr == "") {
+		return errors.New("egress allow-list requires bridge network mode and egress proxy")
+	}
+
+	return nil
+}
+
+// build builds the function image from the archive and creates its container.
+func (s *Service) build(
+	ctx context.Context,
+	name, container string,
+	file io.ReadCloser,
+	createOpts CreateOptions,
+) (*metaData, error) {
+	dir, err := s.buildContext(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.RemoveAll(dir)
+
+	buildStart := time.Now()
+
+	img, err := s.builder.ImageBuild(ctx, dir, ImageTag(name))
+	buildDuration.WithLabelValues(metricStatus(err)).Observe(time.Since(buildStart).Seconds())
+	s.accountBuild(name, time.Since(buildStart))
+
 	if err != nil {
-		return fmt.Errorf("build image: %w", err)
+		buildFailuresTotal.Inc()
+		return nil, fmt.Errorf("build image: %w", err)
 	}
 
 	s.log.Info("build image", "image", img)
 
-	containerID, err := s.builder.ContainerCreate(ctx, img, port)
+	namespace, short := splitName(name)
+
+	opts := builder.ContainerOptions{
+		Image:    img,
+		Name:     container,
+		HostIP:   s.cfg.App.FunctionHostIP,
+		MemoryMB: s.cfg.App.FunctionMemoryMB,
+		Labels: map[string]string{
+			builder.LabelNamespace: namespace,
+			builder.LabelFunction:  short,
+		},
+		Hardening: s.profile.hardening(createOpts.OptOut),
+	}
+
+	if len(createOpts.OptOut) > 0 {
+		opts.Labels[builder.LabelSecurityOptOut] = strings.Join(createOpts.OptOut, ",")
+	}
+
+	var certExpiry time.Time
+
+	if s.ca != nil {
+		opts.TLSDir = s.ca.functionDir(container)
+
+		if certExpiry, err = s.writeCerts(name, opts); err != nil {
+			return nil, fmt.Errorf("issue function certificate: %w", err)
+		}
+
+		opts.Labels[builder.LabelTLS] = "true"
+	}
+
+	var egressToken string
+
+	if len(createOpts.Egress) > 0 {
+		egressToken = newID()
+
+		env, err := s.egressEnv(ctx, egressToken)
+		if err != nil {
+			return nil, fmt.Errorf("egress proxy env: %w", err)
+		}
+
+		opts.Env = append(opts.Env, env...)
+		opts.Labels[builder.LabelEgress] = strings.Join(createOpts.Egress, ",")
+	}
+
+	if s.cfg.App.NetworkMode == networkModeBridge {
+		opts.Network = s.cfg.App.NetworkName
+		// the only way out of the internal network is the egress proxy on the gateway.
+		if createOpts.NoEgress || len(createOpts.Egress) > 0 {
+			opts.Network = s.internalNetwork()
+		}
+
+		opts.Labels[builder.LabelNetwork] = opts.Network
+	} else if opts.Port, err = s.ports.allocate(name); err != nil {
+		return nil, fmt.Errorf("allocate port: %w", err)
+	}
+
+	containerID, err := s.builder.ContainerCreate(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("run builder: %w", err)
+		if opts.Port != 0 {
+			s.ports.release(opts.Port)
+		}
+
+		if opts.TLSDir != "" {
+			_ = os.RemoveAll(opts.TLSDir)
+		}
+
+		return nil, fmt.Errorf("run builder: %w", err)
+	}
+
+	meta := newMetaData(containerID, opts.Port)
+	meta.tls = s.ca != nil
+	meta.network = opts.Network
+	meta.opts = opts
+	meta.create = createOpts
+	meta.egressToken = egressToken
+	meta.certExpiry = certExpiry
+
+	if meta.network != "" && s.cfg.App.NetworkDNS {
+		meta.host = opts.Name
+	}
+
+	if egressToken != "" {
+		rules, _ := parseEgressRules(createOpts.Egress)
+		s.egress.put(egressToken, &egressPolicy{function: name, rules: rules})
+	}
+
+	return meta, nil
+}
+
+// Delete removes the function container and unregisters the function.
+// Invocations in progress are interrupted.
+func (s *Service) Delete(ctx context.Context, name string) error {
+	value, ok := s.register.LoadAndDelete(name)
+	if !ok {
+		return ErrFunctionNotFound
+	}
+
+	meta, ok := value.(*metaData)
+	if !ok {
+		return errors.New("invalid container meta type")
 	}
 
-	s.register.Store(name, newMetaData(containerID, port))
+	meta.mu.Lock()
+	defer meta.mu.Unlock()
+
+	if err := s.builder.ContainerRemove(ctx, meta.containerID); err != nil {
+		s.register.Store(name, meta)
+		return fmt.Errorf("remove container: %w", err)
+	}
+
+	if meta.active > 0 {
+		runningContainers.Dec()
+	}
+
+	s.dispose(meta)
+
+	s.log.Info("function deleted", slog.String("name", name))
 
 	return nil
 }
 
+// remove removes the container which is not in use and releases its resources.
+// Must be called with the meta lock held unless the meta is not registered yet.
+func (s *Service) remove(ctx context.Context, meta *metaData) {
+	if err := s.builder.ContainerRemove(context.WithoutCancel(ctx), meta.containerID); err != nil {
+		s.log.Error("remove container", slog.String("id", meta.short()), "err", err.Error())
+	}
+
+	s.dispose(meta)
+}
+
+// dispose releases the port, egress token and TLS files of the removed container.
+func (s *Service) dispose(meta *metaData) {
+	if meta.port != 0 {
+		s.ports.release(meta.port)
+	}
+
+	if meta.egressToken != "" {
+		s.egress.remove(meta.egressToken)
+	}
+
+	if meta.opts.TLSDir != "" {
+		if err := os.RemoveAll(meta.opts.TLSDir); err != nil {
+			s.log.Error("remove TLS dir", slog.String("id", meta.short()), "err", err.Error())
+		}
+	}
+
+	meta.deleted = true
+}
+
+// Invoke invokes lambda function and returns its response.
+// Invocation ID is taken from the context or generated.
 func (s *Service) Invoke(ctx context.Context, name string, data []byte) ([]byte, error) {
+	var respData []byte
+
+	ctx, _ = ensureInvocationID(ctx)
+	startedAt := time.Now()
+
+	payloadSize.WithLabelValues(name, "request").Observe(float64(len(data)))
+
+	err := s.execute(ctx, name, func(ctx context.Context, meta *metaData) error {
+		var err error
+
+		respData, err = s.makeRequest(ctx, data, meta)
+		if err != nil {
+			return fmt.Errorf("make request: %w", err)
+		}
+
+		return nil
+	})
+
+	s.record(ctx, name, startedAt, data, respData, err)
+	s.account(name, time.Since(startedAt), len(data), len(respData), err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	payloadSize.WithLabelValues(name, "response").Observe(float64(len(respData)))
+
+	return respData, nil
+}
+
+// InvokeStream invokes lambda function and writes its response to w as soon as it is produced.
+func (s *Service) InvokeStream(ctx context.Context, name string, data []byte, w io.Writer) error {
+	ctx, _ = ensureInvocationID(ctx)
+	startedAt := time.Now()
+
+	payloadSize.WithLabelValues(name, "request").Observe(float64(len(data)))
+
+	cw := &countingWriter{w: w}
+
+	err := s.execute(ctx, name, func(ctx context.Context, meta *metaData) error {
+		if err := s.makeStreamRequest(ctx, data, meta, cw); err != nil {
+			return fmt.Errorf("make stream request: %w", err)
+		}
+
+		return nil
+	})
+
+	payloadSize.WithLabelValues(name, "response").Observe(float64(cw.n))
+
+	// streamed response is not kept in memory, so only the payload is recorded.
+	s.record(ctx, name, startedAt, data, nil, err)
+	s.account(name, time.Since(startedAt), len(data), cw.n, err)
+
+	return err
+}
+
+// countingWriter counts bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+
+	return n, err
+}
+
+// execute starts the function container, calls fn and stops the container if it is no longer in use.
+func (s *Service) execute(
+	ctx context.Context,
+	name string,
+	fn func(ctx context.Context, meta *metaData) error,
+) (err error) {
+	start := time.Now()
+
+	ctx, span := tracer.Start(
+		ctx,
+		"lambda.Invoke",
+		trace.WithAttributes(functionAttr(name), attribute.String("faas.invocation_id", InvocationID(ctx))),
+	)
+
+	defer func() {
+		endSpan(span, err)
+
+		invocationsTotal.WithLabelValues(name, metricStatus(err)).Inc()
+		invocationDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+
+		if err != nil {
+			invocationErrorsTotal.WithLabelValues(name).Inc()
+		}
+	}()
+
 	value, ok := s.register.Load(name)
 	if !ok {
-		return nil, fmt.Errorf("function %s not found", name)
+		return fmt.Errorf("function %s: %w", name, ErrFunctionNotFound)
 	}
 
 	containerMeta, ok := value.(*metaData)
 	if !ok {
-		return nil, errors.New("invalid container meta type")
+		return errors.New("invalid container meta type")
 	}
 
-	if err := s.builder.ContainerStart(ctx, containerMeta.containerID); err != nil {
-		return nil, fmt.Errorf("start container: %w", err)
+	if err := s.checkQuota(name); err != nil {
+		return err
 	}
 
-	respData, err := s.makeRequest(ctx, data, containerMeta)
-	if err != nil {
-		return nil, fmt.Errorf("make request: %w", err)
+	if err := s.acquire(ctx, name, containerMeta); err != nil {
+		return err
+	}
+
+	err = fn(ctx, containerMeta)
+
+	if releaseErr := s.release(ctx, containerMeta); releaseErr != nil && err == nil {
+		return releaseErr
+	}
+
+	return err
+}
+
+// acquire starts the function container and its logs collecting if there are no other invocations in progress.
+// Returns ErrThrottled if the function has reached concurrency limit.
+func (s *Service) acquire(ctx context.Context, name string, meta *metaData) error {
+	meta.mu.Lock()
+	defer meta.mu.Unlock()
+
+	if meta.deleted {
+		return fmt.Errorf("function %s: %w", name, ErrFunctionNotFound)
 	}
 
-	if !containerMeta.hotMode {
-		if err := s.builder.ContainerStop(ctx, containerMeta.containerID); err != nil {
-			return nil, fmt.Errorf("stop container: %w", err)
+	if limit := s.cfg.App.MaxConcurrency; limit > 0 && meta.active >= limit {
+		throttlesTotal.WithLabelValues(name).Inc()
+		return ErrThrottled
+	}
+
+	if meta.active == 0 {
+		startedAt := time.Now()
+
+		_, span := tracer.Start(ctx, "container.start", trace.WithAttributes(functionAttr(name)))
+
+		err := s.startContainer(ctx, name, meta)
+		endSpan(span, err)
+
+		if err != nil {
+			return fmt.Errorf("start container: %w", err)
+		}
+
+		if err := s.resolveHost(ctx, meta); err != nil {
+			_ = s.builder.ContainerStop(context.WithoutCancel(ctx), meta.containerID)
+			return err
+		}
+
+		coldStartsTotal.WithLabelValues(name).Inc()
+		runningContainers.Inc()
+
+		if meta.logsDone == nil {
+			s.collectLogs(name, meta, startedAt)
 		}
 	}
 
-	return respData, nil
+	meta.active++
+
+	return nil
+}
+
+// internalNetwork returns the name of the network without egress.
+func (s *Service) internalNetwork() string {
+	return s.cfg.App.NetworkName + "-internal"
 }
 
-// makeRequest makes http request to container with Lambda.
-// Using retry pattern for waiting container ready for requests.
-func (s *Service) makeRequest(ctx context.Context, data []byte, meta *metaData) ([]byte, error) {
-	const numAttempts = 5
+// startContainer starts the function container.
+// In host-port mode the container is recreated with another port if its port was taken by another process.
+func (s *Service) startContainer(ctx context.Context, name string, meta *metaData) error {
+	const maxRecreates = 3
+
+	if err := s.renewCerts(name, meta, false); err != nil {
+		return fmt.Errorf("renew function certificate: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := s.builder.ContainerStart(ctx, meta.containerID)
+		if err == nil || meta.port == 0 || !isBindConflict(err) || attempt == maxRecreates {
+			return err
+		}
+
+		s.log.Warn("port conflict, recreate container", slog.String("name", name), slog.Int("port", meta.port))
 
-	s.log.Info("make request", "size", len(data), "address", meta.address())
+		if err := s.recreate(ctx, name, meta); err != nil {
+			return fmt.Errorf("recreate container: %w", err)
+		}
+	}
+}
 
-	conn, err := grpc.Dial(meta.address(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+// recreate replaces the function container with the new one bound to another host port.
+// Must be called with the meta lock held.
+func (s *Service) recreate(ctx context.Context, name string, meta *metaData) error {
+	port, err := s.ports.allocate(name)
+	if err != nil {
+		return err
+	}
+
+	if err := s.builder.ContainerRemove(ctx, meta.containerID); err != nil {
+		s.ports.release(port)
+		return err
+	}
+
+	opts := meta.opts
+	opts.Port = port
+
+	containerID, err := s.builder.ContainerCreate(ctx, opts)
+	if err != nil {
+		s.ports.release(port)
+		return err
+	}
+
+	if err := s.renewCerts(name, meta, true); err != nil {
+		s.log.Error("renew function certificate", slog.String("name", name), "err", err.Error())
+	}
+
+	// the conflicting port is bound by someone else, so the probe skips it until it is free.
+	s.ports.release(meta.port)
+
+	meta.containerID = containerID
+	meta.port = port
+	meta.opts = opts
+
+	return nil
+}
+
+// writeCerts issues the server certificate to the TLS directory of the container.
+// Process runtime runs functions as the control plane user, so the files are not chowned then.
+func (s *Service) writeCerts(name string, opts builder.ContainerOptions) (time.Time, error) {
+	user := opts.Hardening.User
+	if s.cfg.App.Runtime == RuntimeProcess {
+		user = ""
+	}
+
+	return s.ca.writeFunctionCerts(opts.TLSDir, name, user)
+}
+
+// renewCerts reissues the server certificate of the container when half of its validity is passed or if forced.
+// Containers created with the certificate in the environment are skipped.
+// Must be called with the meta lock held.
+func (s *Service) renewCerts(name string, meta *metaData, force bool) error {
+	if s.ca == nil || meta.opts.TLSDir == "" {
+		return nil
+	}
+
+	if !force && time.Until(meta.certExpiry) > s.ca.validity/2 {
+		return nil
+	}
+
+	expiry, err := s.writeCerts(name, meta.opts)
+	if err != nil {
+		return err
+	}
+
+	meta.certExpiry = expiry
+
+	return nil
+}
+
+// resolveHost sets the container IP address in the private network, it changes on every start.
+func (s *Service) resolveHost(ctx context.Context, meta *metaData) error {
+	if meta.network == "" || s.cfg.App.NetworkDNS {
+		return nil
+	}
+
+	data, err := s.builder.ContainerInspect(ctx, meta.containerID)
+	if err != nil {
+		return fmt.Errorf("inspect container: %w", err)
+	}
+
+	host, ok := data.Addresses[meta.network]
+	if !ok {
+		return fmt.Errorf("container is not attached to network %s", meta.network)
+	}
+
+	meta.host = host
+
+	return nil
+}
+
+// release stops the function container when the last invocation is finished and container is not in hot mode.
+func (s *Service) release(ctx context.Context, meta *metaData) error {
+	meta.mu.Lock()
+	defer meta.mu.Unlock()
+
+	meta.active--
+
+	if meta.active == 0 && meta.retired && !meta.deleted {
+		s.remove(ctx, meta)
+		runningContainers.Dec()
+
+		return nil
+	}
+
+	if meta.active > 0 || meta.hotMode || meta.deleted {
+		return nil
+	}
+
+	if err := s.builder.ContainerStop(context.WithoutCancel(ctx), meta.containerID); err != nil {
+		return fmt.Errorf("stop container: %w", err)
+	}
+
+	runningContainers.Dec()
+
+	s.waitLogs(meta)
+	meta.logsDone = nil
+
+	return nil
+}
+
+// makeRequest makes gRPC request to container with Lambda.
+// The request is sent once after the connection is established, handler error is returned as *FunctionError.
+func (s *Service) makeRequest(ctx context.Context, data []byte, meta *metaData) (_ []byte, err error) {
+	ctx, span := tracer.Start(ctx, "grpc LambdaServer/MakeRequest", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() { endSpan(span, err) }()
+
+	s.log.Info("make request", "size", len(data), "address", meta.address(), "invocation_id", InvocationID(ctx))
+
+	conn, err := s.dial(ctx, meta)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
 
-	var resp []byte
-
-	// Create a gRPC client.
 	client := proto.NewLambdaServerClient(conn)
 
-	if err = retry.Do(
-		func() error {
-			r, err := client.MakeRequest(ctx, &proto.Payload{
-				Data: data,
-			})
-			if err != nil {
-				s.log.Warn("make request", "error", err)
-				return err
-			}
+	resp, err := client.MakeRequest(ctx, &proto.Payload{
+		Data:         data,
+		InvocationId: InvocationID(ctx),
+		Metadata:     requestMetadata(ctx),
+	})
+	if err != nil {
+		s.log.Warn("make request", "error", err)
+		return nil, functionError(err)
+	}
+
+	return resp.Data, nil
+}
 
-			resp = r.Data
+// makeStreamRequest makes streaming request to container with Lambda and copies received chunks to w.
+// The request is sent once, chunks could be already written when the stream fails,
+// so the stream is not retried. Handler error is returned as *FunctionError.
+func (s *Service) makeStreamRequest(ctx context.Context, data []byte, meta *metaData, w io.Writer) (err error) {
+	ctx, span := tracer.Start(ctx, "grpc LambdaServer/StreamRequest", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() { endSpan(span, err) }()
 
+	s.log.Info("make stream request", "size", len(data), "address", meta.address())
+
+	conn, err := s.dial(ctx, meta)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	client := proto.NewLambdaServerClient(conn)
+
+	stream, err := client.StreamRequest(ctx, &proto.Payload{
+		Data:         data,
+		InvocationId: InvocationID(ctx),
+		Metadata:     requestMetadata(ctx),
+	})
+	if err != nil {
+		s.log.Warn("make stream request", "error", err)
+		return functionError(err)
+	}
+
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
 			return nil
-		},
-		retry.DelayType(retry.BackOffDelay),
-		retry.Attempts(numAttempts),
-	); err != nil {
-		return nil, errors.New("failed GRPC request")
+		}
+
+		if err != nil {
+			s.log.Warn("make stream request", "error", err)
+			return functionError(err)
+		}
+
+		if _, err := w.Write(chunk.Data); err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+	}
+}
+
+// dial connects to the function container and waits until the connection is established,
+// so the container which is still starting is waited for without sending the request twice.
+// mTLS is used for containers created with the certificate issued by the internal CA.
+func (s *Service) dial(ctx context.Context, meta *metaData) (*grpc.ClientConn, error) {
+	const (
+		connectTimeout = 10 * time.Second
+		maxBackoff     = time.Second
+	)
+
+	creds := insecure.NewCredentials()
+
+	if meta.tls {
+		if s.clientTLS == nil {
+			return nil, errors.New("function requires mTLS, but it is disabled")
+		}
+
+		creds = credentials.NewTLS(s.clientTLS)
+	}
+
+	backoffConfig := backoff.DefaultConfig
+	backoffConfig.BaseDelay = 100 * time.Millisecond
+	backoffConfig.MaxDelay = maxBackoff
+
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(
+		ctx,
+		meta.address(),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: backoffConfig, MinConnectTimeout: connectTimeout}),
+		grpc.WithBlock(),
+		grpc.WithReturnConnectionError(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFunctionUnavailable, err)
 	}
 
-	return resp, nil
+	return conn, nil
 }
 
 // decompress decompresses tar.gz archive.
+// buildContext unpacks the archive into the new temporary directory with the Dockerfile of the build dir.
+// Every build has its own directory, so concurrent builds and files of the previous builds are not mixed.
+func (s *Service) buildContext(file io.ReadCloser) (string, error) {
+	dir, err := os.MkdirTemp("", "lambda-build-")
+	if err != nil {
+		return "", fmt.Errorf("create build dir: %w", err)
+	}
+
+	// the process runtime builds with go build, so the build dir may have no Dockerfile.
+	dockerfile, err := os.ReadFile(filepath.Join(s.cfg.App.BuildDir, "Dockerfile"))
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, "Dockerfile"), dockerfile, 0o644)
+	}
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("copy Dockerfile: %w", err)
+	}
+
+	if err := s.decompress(dir, file); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("decompress: %w", err)
+	}
+
+	return dir, nil
+}
+
 func (s *Service) decompress(dst string, file io.ReadCloser) error {
 	uncompressedStream, err := gzip.NewReader(file)
 	if err != nil {
@@ -200,23 +949,33 @@ func (s *Service) decompress(dst string, file io.ReadCloser) error {
 			continue // TODO: need to Google it :)
 		}
 
+		// entries must not escape the build dir of the function.
+		if !filepath.IsLocal(header.Name) {
+			return fmt.Errorf("invalid path %s", header.Name)
+		}
+
 		target := filepath.Join(dst, header.Name)
 
 		switch header.Typeflag {
 		case tar.TypeDir:
-			if err := os.Mkdir(target, 0755); err != nil {
+			if err := os.MkdirAll(target, 0755); err != nil {
 				return fmt.Errorf("mkdir: %w", err)
 			}
 			s.log.Debug("create dir", "path", target)
 		case tar.TypeReg:
-			outFile, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("mkdir: %w", err)
+			}
+
+			outFile, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR|os.O_TRUNC, os.FileMode(header.Mode))
 			if err != nil {
 				return fmt.Errorf("open file: %w", err)
 			}
 
-			defer outFile.Close()
+			_, err = io.Copy(outFile, tarReader)
+			outFile.Close()
 
-			if _, err := io.Copy(outFile, tarReader); err != nil {
+			if err != nil {
 				return err
 			}
 
@@ -229,27 +988,92 @@ func (s *Service) decompress(dst string, file io.ReadCloser) error {
 	return nil
 }
 
-// parseContainerData parses container data and returns container image tag (user func name) and port.
-func (s *Service) parseContainerData(data types.ContainerJSON) (string, int, error) {
-	image := strings.Split(data.Config.Image, ":")
-	if len(image) != 2 {
-		return "", 0, errors.New("invalid image name")
+// parseContainerData parses container data and returns function registry key and port.
+// Function is taken from container labels or from image tag (user func name) for legacy containers.
+func (s *Service) parseContainerData(data builder.Container) (string, int, error) {
+	var name string
+
+	if short, ok := data.Labels[builder.LabelFunction]; ok {
+		namespace := data.Labels[builder.LabelNamespace]
+		if namespace == "" {
+			namespace = DefaultNamespace
+		}
+
+		name = qualify(namespace, short)
+	} else {
+		image := strings.Split(data.Image, ":")
+		if len(image) != 2 {
+			return "", 0, errors.New("invalid image name")
+		}
+
+		name = qualify(DefaultNamespace, image[1])
+	}
+
+	// containers in the private network have no published ports.
+	if data.Labels[builder.LabelNetwork] != "" {
+		return name, 0, nil
 	}
 
-	tag := image[1]
+	if data.Port == 0 {
+		return "", 0, errors.New("port not found")
+	}
 
-	for _, v := range data.ContainerJSONBase.HostConfig.PortBindings {
-		if len(v) == 0 {
-			continue
+	return name, data.Port, nil
+}
+
+// containerOptions returns options of the existing container to recreate it.
+func containerOptions(data builder.Container, port int) builder.ContainerOptions {
+	return builder.ContainerOptions{
+		Image:    data.Image,
+		Name:     data.Name,
+		Port:     port,
+		HostIP:   data.HostIP,
+		MemoryMB: data.MemoryMB,
+		Labels:   data.Labels,
+		Env:      data.Env,
+		Network:  data.Labels[builder.LabelNetwork],
+
+		Hardening: data.Hardening,
+		TLSDir:    data.TLSDir,
+	}
+}
+
+// FunctionInfo describes registered function.
+type FunctionInfo struct {
+	Namespace   string `json:"namespace"`
+	Name        string `json:"name"`
+	ContainerID string `json:"container_id"`
+	Port        int    `json:"port,omitempty"`
+	Network     string `json:"network,omitempty"`
+	CreateOptions
+}
+
+// List returns functions of the namespace sorted by name.
+func (s *Service) List(namespace string) []FunctionInfo {
+	result := make([]FunctionInfo, 0)
+
+	s.register.Range(func(key, value any) bool {
+		ns, name := splitName(key.(string))
+		if ns != namespace {
+			return true
 		}
 
-		port, err := strconv.Atoi(v[0].HostPort)
-		if err != nil {
-			return "", 0, fmt.Errorf("parse port: %w", err)
+		if meta, ok := value.(*metaData); ok {
+			result = append(result, FunctionInfo{
+				Namespace:   ns,
+				Name:        name,
+				ContainerID: meta.containerID,
+				Port:        meta.port,
+				Network:     meta.network,
+
+				CreateOptions: meta.create,
+			})
 		}
 
-		return tag, port, nil
-	}
+		return true
+	})
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
 
-	return "", 0, errors.New("port not found")
+	return result
 }
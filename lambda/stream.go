@@ -0,0 +1,115 @@
+package lambda
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	errorTrailer    = "X-Lambda-Error"
+	eventStreamMIME = "text/event-stream"
+)
+
+// flushWriter writes the data to the client and flushes it immediately.
+type flushWriter struct {
+	w     io.Writer
+	rc    *http.ResponseController
+	wrote bool
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+
+	f.wrote = true
+
+	return n, f.rc.Flush()
+}
+
+// sseWriter writes every chunk as Server-Sent Event.
+type sseWriter struct {
+	w io.Writer
+}
+
+func (s *sseWriter) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+
+	for _, line := range strings.Split(strings.TrimSuffix(string(p), "\n"), "\n") {
+		buf.WriteString("data: ")
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString("\n")
+
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// invokeStream http endpoint for invoke lambda function with streamed response.
+// Response is sent using chunked transfer encoding or as Server-Sent Events if client accepts them.
+func (e *Endpoint) invokeStream(w http.ResponseWriter, r *http.Request) {
+	name := funcName(r)
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		e.logger.Error("stream: read body error", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, invocationID := ensureInvocationID(r.Context())
+	w.Header().Set(invocationIDHeader, invocationID)
+
+	e.logger.Info("got lambda stream request", slog.Any("func_name", name), slog.String("invocation_id", invocationID))
+
+	rc := http.NewResponseController(w)
+
+	// streaming response can last longer than server write timeout.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		e.logger.Warn("stream: reset write deadline", "err", err.Error())
+	}
+
+	sse := strings.Contains(r.Header.Get("Accept"), eventStreamMIME)
+
+	if sse {
+		w.Header().Set("Content-Type", eventStreamMIME)
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Trailer", errorTrailer)
+	}
+
+	fw := &flushWriter{w: w, rc: rc}
+
+	var out io.Writer = fw
+	if sse {
+		out = &sseWriter{w: fw}
+	}
+
+	if err := e.svc.InvokeStream(ctx, name, data, out); err != nil {
+		e.logger.Error("stream: invoke service error", "err", err.Error())
+
+		if !fw.wrote {
+			http.Error(w, err.Error(), httpStatus(err))
+			return
+		}
+
+		if sse {
+			_, _ = fmt.Fprintf(fw, "event: error\ndata: %s\n\n", err.Error())
+			return
+		}
+
+		w.Header().Set(errorTrailer, err.Error())
+	}
+}
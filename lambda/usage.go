@@ -0,0 +1,599 @@
+package lambda
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const dayLayout = time.DateOnly
+
+// ErrQuotaExceeded is returned when the function or its namespace has exhausted the daily quota.
+var ErrQuotaExceeded = errors.New("function quota exceeded")
+
+// Usage is a daily usage rollup of the function or the whole namespace.
+type Usage struct {
+	Namespace    string  `json:"namespace"`
+	Function     string  `json:"function,omitempty"`
+	Day          string  `json:"day"`
+	Invocations  int64   `json:"invocations"`
+	Errors       int64   `json:"errors"`
+	GBSeconds    float64 `json:"gb_seconds"`
+	Builds       int64   `json:"builds"`
+	BuildMinutes float64 `json:"build_minutes"`
+	BytesIn      int64   `json:"bytes_in"`
+	BytesOut     int64   `json:"bytes_out"`
+}
+
+// Quota is a daily limit of the function or namespace usage, zero value means no limit.
+type Quota struct {
+	MaxInvocationsPerDay int64   `json:"max_invocations_per_day,omitempty"`
+	MaxGBSecondsPerDay   float64 `json:"max_gb_seconds_per_day,omitempty"`
+	MaxBytesPerDay       int64   `json:"max_bytes_per_day,omitempty"`
+}
+
+// check returns error if the usage has reached the quota.
+func (q Quota) check(u Usage) error {
+	switch {
+	case q.MaxInvocationsPerDay > 0 && u.Invocations >= q.MaxInvocationsPerDay:
+		return fmt.Errorf("%w: %d invocations per day", ErrQuotaExceeded, q.MaxInvocationsPerDay)
+	case q.MaxGBSecondsPerDay > 0 && u.GBSeconds >= q.MaxGBSecondsPerDay:
+		return fmt.Errorf("%w: %g GB-seconds per day", ErrQuotaExceeded, q.MaxGBSecondsPerDay)
+	case q.MaxBytesPerDay > 0 && u.BytesIn+u.BytesOut >= q.MaxBytesPerDay:
+		return fmt.Errorf("%w: %d bytes per day", ErrQuotaExceeded, q.MaxBytesPerDay)
+	}
+
+	return nil
+}
+
+type usageKey struct {
+	function string
+	day      string
+}
+
+// usageStore accumulates daily usage and keeps quotas of the functions and namespaces.
+// Quotas are persisted in the JSON file, usage rollups are kept in memory.
+type usageStore struct {
+	mu        sync.RWMutex
+	retention int
+	path      string
+	days      map[usageKey]*Usage
+	quotas    map[string]Quota
+	nsQuotas  map[string]Quota
+}
+
+// quotaFile is the content of the quotas file.
+type quotaFile struct {
+	Functions  map[string]Quota `json:"functions"`
+	Namespaces map[string]Quota `json:"namespaces"`
+}
+
+// newUsageStore loads quotas from the file, missing file means no quotas.
+func newUsageStore(retention int, path string) (*usageStore, error) {
+	var file quotaFile
+
+	if err := readJSONFile(path, &file); err != nil {
+		return nil, err
+	}
+
+	s := &usageStore{
+		retention: retention,
+		path:      path,
+		days:      make(map[usageKey]*Usage),
+		quotas:    file.Functions,
+		nsQuotas:  file.Namespaces,
+	}
+
+	if s.quotas == nil {
+		s.quotas = make(map[string]Quota)
+	}
+
+	if s.nsQuotas == nil {
+		s.nsQuotas = make(map[string]Quota)
+	}
+
+	return s, nil
+}
+
+// save writes quotas to the file atomically. Must be called with the lock held.
+func (s *usageStore) save() error {
+	return writeJSONFile(s.path, quotaFile{Functions: s.quotas, Namespaces: s.nsQuotas})
+}
+
+// add adds the usage counters of other to u.
+func (u *Usage) add(other Usage) {
+	u.Invocations += other.Invocations
+	u.Errors += other.Errors
+	u.GBSeconds += other.GBSeconds
+	u.Builds += other.Builds
+	u.BuildMinutes += other.BuildMinutes
+	u.BytesIn += other.BytesIn
+	u.BytesOut += other.BytesOut
+}
+
+// update applies fn to the current day usage of the function.
+func (s *usageStore) update(name string, fn func(u *Usage)) {
+	now := time.Now().UTC()
+	key := usageKey{function: name, day: now.Format(dayLayout)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.days[key]
+	if !ok {
+		namespace, short := splitName(name)
+
+		u = &Usage{Namespace: namespace, Function: short, Day: key.day}
+		s.days[key] = u
+
+		s.prune(now)
+	}
+
+	fn(u)
+}
+
+// prune removes rollups older than retention. Must be called with the lock held.
+func (s *usageStore) prune(now time.Time) {
+	if s.retention <= 0 {
+		return
+	}
+
+	oldest := now.AddDate(0, 0, -s.retention).Format(dayLayout)
+
+	for key := range s.days {
+		if key.day < oldest {
+			delete(s.days, key)
+		}
+	}
+}
+
+// today returns the current day usage of the function.
+func (s *usageStore) today(name string) Usage {
+	key := usageKey{function: name, day: time.Now().UTC().Format(dayLayout)}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if u, ok := s.days[key]; ok {
+		return *u
+	}
+
+	namespace, short := splitName(name)
+
+	return Usage{Namespace: namespace, Function: short, Day: key.day}
+}
+
+// namespaceToday returns the current day usage of all functions in the namespace.
+func (s *usageStore) namespaceToday(namespace string) Usage {
+	day := time.Now().UTC().Format(dayLayout)
+	total := Usage{Namespace: namespace, Day: day}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for key, u := range s.days {
+		if key.day == day && u.Namespace == namespace {
+			total.add(*u)
+		}
+	}
+
+	return total
+}
+
+// list returns daily rollups matching the filter sorted by day and function.
+// Empty namespace or function matches all, days are inclusive.
+func (s *usageStore) list(namespace, name, from, to string) []Usage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Usage, 0)
+
+	for key, u := range s.days {
+		if (namespace != "" && u.Namespace != namespace) || (name != "" && key.function != name) {
+			continue
+		}
+
+		if (from != "" && key.day < from) || (to != "" && key.day > to) {
+			continue
+		}
+
+		result = append(result, *u)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Day != result[j].Day {
+			return result[i].Day < result[j].Day
+		}
+
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+
+		return result[i].Function < result[j].Function
+	})
+
+	return result
+}
+
+// rollup sums function rollups of the namespace by day.
+func rollup(namespace string, usage []Usage) []Usage {
+	result := make([]Usage, 0)
+
+	for _, u := range usage {
+		if len(result) == 0 || result[len(result)-1].Day != u.Day {
+			result = append(result, Usage{Namespace: namespace, Day: u.Day})
+		}
+
+		result[len(result)-1].add(u)
+	}
+
+	return result
+}
+
+func (s *usageStore) quota(name string) (Quota, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	q, ok := s.quotas[name]
+
+	return q, ok
+}
+
+func (s *usageStore) setQuota(name string, q Quota) error {
+	return s.set(s.quotas, name, q)
+}
+
+func (s *usageStore) deleteQuota(name string) (bool, error) {
+	return s.delete(s.quotas, name)
+}
+
+func (s *usageStore) namespaceQuota(namespace string) (Quota, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	q, ok := s.nsQuotas[namespace]
+
+	return q, ok
+}
+
+func (s *usageStore) setNamespaceQuota(namespace string, q Quota) error {
+	return s.set(s.nsQuotas, namespace, q)
+}
+
+func (s *usageStore) deleteNamespaceQuota(namespace string) (bool, error) {
+	return s.delete(s.nsQuotas, namespace)
+}
+
+// set stores the quota of the function or namespace, it is reverted if the file could not be saved.
+func (s *usageStore) set(quotas map[string]Quota, key string, q Quota) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before, ok := quotas[key]
+	quotas[key] = q
+
+	if err := s.save(); err != nil {
+		if ok {
+			quotas[key] = before
+		} else {
+			delete(quotas, key)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// delete removes the quota of the function or namespace, it is reverted if the file could not be saved.
+func (s *usageStore) delete(quotas map[string]Quota, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q, ok := quotas[key]
+	if !ok {
+		return false, nil
+	}
+
+	delete(quotas, key)
+
+	if err := s.save(); err != nil {
+		quotas[key] = q
+		return false, err
+	}
+
+	return true, nil
+}
+
+// checkQuota returns ErrQuotaExceeded if the function or its namespace has exhausted the daily quota.
+func (s *Service) checkQuota(name string) error {
+	if q, ok := s.usage.quota(name); ok {
+		if err := q.check(s.usage.today(name)); err != nil {
+			return err
+		}
+	}
+
+	namespace, _ := splitName(name)
+
+	if q, ok := s.usage.namespaceQuota(namespace); ok {
+		if err := q.check(s.usage.namespaceToday(namespace)); err != nil {
+			return fmt.Errorf("namespace %s: %w", namespace, err)
+		}
+	}
+
+	return nil
+}
+
+// account adds the invocation to the function usage.
+// Invocations rejected before dispatch are not accounted.
+func (s *Service) account(name string, duration time.Duration, in, out int, err error) {
+	if errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrThrottled) {
+		return
+	}
+
+	gbSeconds := float64(s.cfg.App.FunctionMemoryMB) / 1024 * duration.Seconds()
+
+	s.usage.update(name, func(u *Usage) {
+		u.Invocations++
+		u.GBSeconds += gbSeconds
+		u.BytesIn += int64(in)
+		u.BytesOut += int64(out)
+
+		if err != nil {
+			u.Errors++
+		}
+	})
+}
+
+// accountBuild adds the image build to the function usage.
+func (s *Service) accountBuild(name string, duration time.Duration) {
+	s.usage.update(name, func(u *Usage) {
+		u.Builds++
+		u.BuildMinutes += duration.Minutes()
+	})
+}
+
+// Usage returns daily usage rollups of the functions matching the filter between days inclusive.
+// Empty namespace or name matches all.
+func (s *Service) Usage(namespace, name, from, to string) []Usage {
+	return s.usage.list(namespace, name, from, to)
+}
+
+// NamespaceUsage returns daily usage rollups of the whole namespace between days inclusive.
+func (s *Service) NamespaceUsage(namespace, from, to string) []Usage {
+	return rollup(namespace, s.usage.list(namespace, "", from, to))
+}
+
+// Quota returns the function quota.
+func (s *Service) Quota(name string) (Quota, bool) {
+	return s.usage.quota(name)
+}
+
+// SetQuota sets the function quota.
+func (s *Service) SetQuota(name string, q Quota) error {
+	if err := s.usage.setQuota(name, q); err != nil {
+		return fmt.Errorf("save quota: %w", err)
+	}
+
+	s.log.Info("quota set", slog.String("func_name", name), slog.Any("quota", q))
+
+	return nil
+}
+
+// DeleteQuota removes the function quota.
+func (s *Service) DeleteQuota(name string) (bool, error) {
+	return s.usage.deleteQuota(name)
+}
+
+// NamespaceQuota returns the namespace quota.
+func (s *Service) NamespaceQuota(namespace string) (Quota, bool) {
+	return s.usage.namespaceQuota(namespace)
+}
+
+// SetNamespaceQuota sets the namespace quota.
+func (s *Service) SetNamespaceQuota(namespace string, q Quota) error {
+	if err := s.usage.setNamespaceQuota(namespace, q); err != nil {
+		return fmt.Errorf("save quota: %w", err)
+	}
+
+	s.log.Info("namespace quota set", slog.String("namespace", namespace), slog.Any("quota", q))
+
+	return nil
+}
+
+// DeleteNamespaceQuota removes the namespace quota.
+func (s *Service) DeleteNamespaceQuota(namespace string) (bool, error) {
+	return s.usage.deleteNamespaceQuota(namespace)
+}
+
+// usageRange returns from and to days of the usage request.
+func usageRange(r *http.Request) (string, string, error) {
+	query := r.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
+
+	for _, day := range []string{from, to} {
+		if day == "" {
+			continue
+		}
+
+		if _, err := time.Parse(dayLayout, day); err != nil {
+			return "", "", errors.New("from and to must be dates in YYYY-MM-DD format")
+		}
+	}
+
+	return from, to, nil
+}
+
+// usage http endpoint for get daily usage rollups of the functions.
+// Optional function parameter accepts "namespace/name" or "name" of the default namespace.
+func (e *Endpoint) usage(w http.ResponseWriter, r *http.Request) {
+	from, to, err := usageRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var name string
+
+	if ref := r.URL.Query().Get("function"); ref != "" {
+		if name, err = parseFunction(ref); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	e.writeJSON(w, http.StatusOK, e.svc.Usage(r.URL.Query().Get("namespace"), name, from, to))
+}
+
+// namespaceUsage http endpoint for get daily usage rollups of the namespace.
+func (e *Endpoint) namespaceUsage(w http.ResponseWriter, r *http.Request) {
+	from, to, err := usageRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	e.writeJSON(w, http.StatusOK, e.svc.NamespaceUsage(requestNamespace(r), from, to))
+}
+
+// decodeQuota decodes and validates quota from the request body.
+func decodeQuota(r *http.Request) (Quota, error) {
+	var q Quota
+
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		return Quota{}, err
+	}
+
+	if q.MaxInvocationsPerDay < 0 || q.MaxGBSecondsPerDay < 0 || q.MaxBytesPerDay < 0 {
+		return Quota{}, errors.New("quota limits must not be negative")
+	}
+
+	return q, nil
+}
+
+// getQuota http endpoint for get the function quota.
+func (e *Endpoint) getQuota(w http.ResponseWriter, r *http.Request) {
+	name := funcName(r)
+
+	q, ok := e.svc.Quota(name)
+	if !ok {
+		http.Error(w, "quota not found", http.StatusNotFound)
+		return
+	}
+
+	e.writeJSON(w, http.StatusOK, q)
+}
+
+// setQuota http endpoint for set the function quota.
+func (e *Endpoint) setQuota(w http.ResponseWriter, r *http.Request) {
+	name := funcName(r)
+	before := optional(e.svc.Quota(name))
+
+	q, err := decodeQuota(r)
+	if err != nil {
+		e.writeAudit(r, AuditQuotaSet, name, before, before, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := e.svc.SetQuota(name, q); err != nil {
+		e.writeAudit(r, AuditQuotaSet, name, before, before, err)
+		e.logger.Error("quota: service error", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	e.writeAudit(r, AuditQuotaSet, name, before, q, nil)
+
+	e.writeJSON(w, http.StatusOK, q)
+}
+
+// deleteQuota http endpoint for remove the function quota.
+func (e *Endpoint) deleteQuota(w http.ResponseWriter, r *http.Request) {
+	name := funcName(r)
+	before := optional(e.svc.Quota(name))
+
+	deleted, err := e.svc.DeleteQuota(name)
+	if err != nil {
+		e.writeAudit(r, AuditQuotaDelete, name, before, before, err)
+		e.logger.Error("quota: service error", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if !deleted {
+		http.Error(w, "quota not found", http.StatusNotFound)
+		return
+	}
+
+	e.writeAudit(r, AuditQuotaDelete, name, before, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getNamespaceQuota http endpoint for get the namespace quota.
+func (e *Endpoint) getNamespaceQuota(w http.ResponseWriter, r *http.Request) {
+	q, ok := e.svc.NamespaceQuota(requestNamespace(r))
+	if !ok {
+		http.Error(w, "quota not found", http.StatusNotFound)
+		return
+	}
+
+	e.writeJSON(w, http.StatusOK, q)
+}
+
+// setNamespaceQuota http endpoint for set the namespace quota.
+func (e *Endpoint) setNamespaceQuota(w http.ResponseWriter, r *http.Request) {
+	namespace := requestNamespace(r)
+	before := optional(e.svc.NamespaceQuota(namespace))
+
+	q, err := decodeQuota(r)
+	if err != nil {
+		e.writeAudit(r, AuditNamespaceQuotaSet, namespace, before, before, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := e.svc.SetNamespaceQuota(namespace, q); err != nil {
+		e.writeAudit(r, AuditNamespaceQuotaSet, namespace, before, before, err)
+		e.logger.Error("quota: service error", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	e.writeAudit(r, AuditNamespaceQuotaSet, namespace, before, q, nil)
+
+	e.writeJSON(w, http.StatusOK, q)
+}
+
+// deleteNamespaceQuota http endpoint for remove the namespace quota.
+func (e *Endpoint) deleteNamespaceQuota(w http.ResponseWriter, r *http.Request) {
+	namespace := requestNamespace(r)
+	before := optional(e.svc.NamespaceQuota(namespace))
+
+	deleted, err := e.svc.DeleteNamespaceQuota(namespace)
+	if err != nil {
+		e.writeAudit(r, AuditNamespaceQuotaDelete, namespace, before, before, err)
+		e.logger.Error("quota: service error", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if !deleted {
+		http.Error(w, "quota not found", http.StatusNotFound)
+		return
+	}
+
+	e.writeAudit(r, AuditNamespaceQuotaDelete, namespace, before, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,58 @@
+package lambda
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestUsageStoreQuotaPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotas.json")
+
+	store, err := newUsageStore(31, path)
+	if err != nil {
+		t.Fatalf("new usage store: %v", err)
+	}
+
+	hello := Quota{MaxInvocationsPerDay: 100}
+	team := Quota{MaxGBSecondsPerDay: 1.5}
+
+	if err := store.setQuota("default/hello", hello); err != nil {
+		t.Fatalf("set quota: %v", err)
+	}
+
+	if err := store.setQuota("default/other", hello); err != nil {
+		t.Fatalf("set quota: %v", err)
+	}
+
+	if err := store.setNamespaceQuota("team-a", team); err != nil {
+		t.Fatalf("set namespace quota: %v", err)
+	}
+
+	if deleted, err := store.deleteQuota("default/other"); err != nil || !deleted {
+		t.Fatalf("delete quota: %v %v", deleted, err)
+	}
+
+	store.update("default/hello", func(u *Usage) { u.Invocations++ })
+
+	reloaded, err := newUsageStore(31, path)
+	if err != nil {
+		t.Fatalf("reload usage store: %v", err)
+	}
+
+	if q, ok := reloaded.quota("default/hello"); !ok || q != hello {
+		t.Fatalf("function quota %+v %v", q, ok)
+	}
+
+	if _, ok := reloaded.quota("default/other"); ok {
+		t.Fatal("deleted quota is loaded")
+	}
+
+	if q, ok := reloaded.namespaceQuota("team-a"); !ok || q != team {
+		t.Fatalf("namespace quota %+v %v", q, ok)
+	}
+
+	// usage rollups are not persisted.
+	if u := reloaded.today("default/hello"); u.Invocations != 0 {
+		t.Fatalf("usage is loaded: %+v", u)
+	}
+}
@@ -0,0 +1,243 @@
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket event types.
+const (
+	WebSocketConnect    = "connect"
+	WebSocketMessage    = "message"
+	WebSocketDisconnect = "disconnect"
+)
+
+// WebSocketEvent is a payload which the function receives for every WebSocket session event.
+type WebSocketEvent struct {
+	ConnectionID string `json:"connection_id"`
+	Type         string `json:"type"`
+	Body         string `json:"body,omitempty"`
+}
+
+// ErrConnectionNotFound is returned when WebSocket connection is closed or unknown.
+var ErrConnectionNotFound = errors.New("connection not found")
+
+// wsConn is an open WebSocket session.
+type wsConn struct {
+	id   string
+	name string
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+// send sends the message to the client. Writes are serialized as required by websocket.Conn.
+func (c *wsConn) send(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// wsHub is a registry of the open WebSocket sessions.
+type wsHub struct {
+	mu    sync.RWMutex
+	conns map[string]*wsConn
+}
+
+func newWSHub() *wsHub {
+	return &wsHub{conns: make(map[string]*wsConn)}
+}
+
+func (h *wsHub) add(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.conns[c.id] = c
+}
+
+func (h *wsHub) remove(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.conns, id)
+}
+
+func (h *wsHub) get(id string) (*wsConn, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	c, ok := h.conns[id]
+
+	return c, ok
+}
+
+var upgrader = websocket.Upgrader{}
+
+// webSocket http endpoint which keeps WebSocket session open and forwards every client message to the function.
+func (e *Endpoint) webSocket(w http.ResponseWriter, r *http.Request) {
+	name := funcName(r)
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		e.logger.Error("ws: upgrade error", "err", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	c := &wsConn{id: newID(), name: name, conn: conn}
+	log := e.logger.With(slog.String("func_name", name), slog.String("connection_id", c.id))
+
+	if _, err := e.invokeWS(r.Context(), c, WebSocketConnect, nil); err != nil {
+		log.Error("ws: connect event error", "err", err.Error())
+		_ = conn.WriteMessage(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "connect rejected"),
+		)
+
+		return
+	}
+
+	e.conns.add(c)
+	log.Info("ws: connected")
+
+	defer func() {
+		e.conns.remove(c.id)
+
+		if _, err := e.invokeWS(context.WithoutCancel(r.Context()), c, WebSocketDisconnect, nil); err != nil {
+			log.Error("ws: disconnect event error", "err", err.Error())
+		}
+
+		log.Info("ws: disconnected")
+	}()
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Warn("ws: read error", "err", err.Error())
+			}
+
+			return
+		}
+
+		respData, err := e.invokeWS(r.Context(), c, WebSocketMessage, msg)
+		if err != nil {
+			log.Error("ws: message event error", "err", err.Error())
+			continue
+		}
+
+		if len(respData) == 0 {
+			continue
+		}
+
+		if err := c.send(respData); err != nil {
+			log.Warn("ws: write error", "err", err.Error())
+			return
+		}
+	}
+}
+
+// invokeWS invokes the function with WebSocketEvent payload.
+func (e *Endpoint) invokeWS(ctx context.Context, c *wsConn, eventType string, body []byte) ([]byte, error) {
+	data, err := json.Marshal(WebSocketEvent{ConnectionID: c.id, Type: eventType, Body: string(body)})
+	if err != nil {
+		return nil, fmt.Errorf("marshal event: %w", err)
+	}
+
+	return e.svc.Invoke(ctx, c.name, data)
+}
+
+// postToConnection http endpoint for push message to the WebSocket connection.
+func (e *Endpoint) postToConnection(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	c, ok := e.conns.get(id)
+	if !ok {
+		http.Error(w, ErrConnectionNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := c.send(data); err != nil {
+		e.logger.Error("ws: post to connection error", "connection_id", id, "err", err.Error())
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteConnection http endpoint for close the WebSocket connection.
+func (e *Endpoint) deleteConnection(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	c, ok := e.conns.get(id)
+	if !ok {
+		http.Error(w, ErrConnectionNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	// closing the connection interrupts read loop which emits disconnect event.
+	if err := c.conn.Close(); err != nil {
+		e.logger.Warn("ws: close connection error", "connection_id", id, "err", err.Error())
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// connectionFunction returns registry key of the function owning the connection of the request.
+func (e *Endpoint) connectionFunction(r *http.Request) string {
+	if c, ok := e.conns.get(mux.Vars(r)["id"]); ok {
+		return c.name
+	}
+
+	return ""
+}
+
+// PostToConnection sends the message to the WebSocket connection via lambda-go API.
+// It can be called by the function to push messages to the client.
+// API key is taken from LAMBDA_API_KEY environment variable if the API requires authentication.
+func PostToConnection(ctx context.Context, apiAddr, connectionID string, data []byte) error {
+	url := fmt.Sprintf("%s/connections/%s", apiAddr, connectionID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+
+	if key := os.Getenv("LAMBDA_API_KEY"); key != "" {
+		req.Header.Set(apiKeyHeader, key)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return ErrConnectionNotFound
+	default:
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+}
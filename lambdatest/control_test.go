@@ -0,0 +1,253 @@
+package lambdatest_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/ihippik/lambda-go/lambda"
+	"github.com/ihippik/lambda-go/lambda/proto"
+	"github.com/ihippik/lambda-go/lambdatest"
+)
+
+const adminKey = "admin-secret"
+
+// controlPlane serves the gRPC management API of the harness on the in-memory listener and returns its client.
+func controlPlane(t *testing.T, h *lambdatest.Harness) proto.ControlPlaneClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- h.Endpoint.ServeGRPC(ctx, lis)
+	}()
+
+	conn, err := grpc.DialContext(
+		ctx,
+		"bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		cancel()
+
+		if err := <-done; err != nil {
+			t.Errorf("serve grpc: %v", err)
+		}
+	})
+
+	return proto.NewControlPlaneClient(conn)
+}
+
+// withKey returns context which sends the API key in the call metadata.
+func withKey(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "x-api-key", key)
+}
+
+// mintKey mints the API key through the HTTP API.
+func mintKey(t *testing.T, h *lambdatest.Harness, key lambda.APIKey) string {
+	t.Helper()
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.URL+"/keys", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+
+	req.Header.Set("X-API-Key", adminKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("mint key: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var minted struct {
+		Key string `json:"key"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&minted); err != nil || resp.StatusCode != http.StatusCreated {
+		t.Fatalf("mint key: %d %v", resp.StatusCode, err)
+	}
+
+	return minted.Key
+}
+
+func TestControlPlane(t *testing.T) {
+	rt := lambdatest.NewRuntime()
+	rt.Handle("hello", func(ctx context.Context, payload []byte) ([]byte, error) {
+		rt.Log("hello", "stdout", `{"msg":"greeting","invocation_id":"`+lambda.InvocationID(ctx)+`"}`)
+		return append([]byte("hello "), payload...), nil
+	})
+
+	h := lambdatest.New(t, rt, map[string]string{"AUTH_ENABLED": "true", "AUTH_BOOTSTRAP_KEY": adminKey})
+	client := controlPlane(t, h)
+	admin := withKey(context.Background(), adminKey)
+
+	// random data makes the archive larger than the chunk, so it is streamed in several chunks after the spec.
+	data := make([]byte, 2*proto.UploadChunkSize)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("random data: %v", err)
+	}
+
+	archive, err := lambdatest.Archive(map[string]string{"main.go": "package main\n", "data.bin": string(data)})
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	spec := &proto.FunctionSpec{Function: &proto.FunctionRef{Name: "hello"}}
+
+	fn, err := proto.CreateFunction(admin, client, spec, bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("create function: %v", err)
+	}
+
+	if fn.GetNamespace() != lambda.DefaultNamespace || fn.GetName() != "hello" {
+		t.Fatalf("unexpected function %v", fn)
+	}
+
+	_, err = proto.CreateFunction(admin, client, spec, bytes.NewReader(archive))
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("create existing function: %v", err)
+	}
+
+	var header metadata.MD
+
+	resp, err := client.Invoke(admin, &proto.InvokeRequest{Function: spec.GetFunction(), Data: []byte("grpc")}, grpc.Header(&header))
+	if err != nil {
+		t.Fatalf("invoke: %v", err)
+	}
+
+	if string(resp.GetData()) != "hello grpc" || resp.GetInvocationId() == "" {
+		t.Fatalf("unexpected response %v", resp)
+	}
+
+	if ids := header.Get("x-lambda-invocation-id"); len(ids) != 1 || ids[0] != resp.GetInvocationId() {
+		t.Fatalf("unexpected header %v", header)
+	}
+
+	t.Run("permission denied", func(t *testing.T) {
+		key := withKey(context.Background(), mintKey(t, h, lambda.APIKey{
+			Scopes:     []string{lambda.ScopeFunctionInvoke},
+			Namespaces: []string{"team-a"},
+		}))
+
+		_, err := client.Invoke(key, &proto.InvokeRequest{Function: spec.GetFunction()})
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("invoke function of other namespace: %v", err)
+		}
+
+		teamSpec := &proto.FunctionSpec{Function: &proto.FunctionRef{Namespace: "team-a", Name: "hello"}}
+
+		_, err = proto.CreateFunction(key, client, teamSpec, bytes.NewReader(archive))
+		if status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("create without scope: %v", err)
+		}
+
+		_, err = client.Invoke(context.Background(), &proto.InvokeRequest{Function: spec.GetFunction()})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("invoke without key: %v", err)
+		}
+	})
+
+	t.Run("tail logs", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(admin, 5*time.Second)
+		defer cancel()
+
+		stream, err := client.TailLogs(ctx, &proto.TailLogsRequest{
+			Function:     spec.GetFunction(),
+			InvocationId: resp.GetInvocationId(),
+		})
+		if err != nil {
+			t.Fatalf("tail logs: %v", err)
+		}
+
+		// the captured line of the finished invocation is sent from history.
+		entry, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("receive history: %v", err)
+		}
+
+		if entry.GetInvocationId() != resp.GetInvocationId() || !strings.Contains(entry.GetMessage(), "greeting") {
+			t.Fatalf("unexpected entry %v", entry)
+		}
+
+		// lines of the new invocations are sent as they are captured.
+		tail, err := client.TailLogs(ctx, &proto.TailLogsRequest{Function: spec.GetFunction()})
+		if err != nil {
+			t.Fatalf("tail logs: %v", err)
+		}
+
+		if _, err := tail.Recv(); err != nil {
+			t.Fatalf("receive history: %v", err)
+		}
+
+		next, err := client.Invoke(admin, &proto.InvokeRequest{Function: spec.GetFunction()})
+		if err != nil {
+			t.Fatalf("invoke: %v", err)
+		}
+
+		if entry, err = tail.Recv(); err != nil || entry.GetInvocationId() != next.GetInvocationId() {
+			t.Fatalf("receive tail: %v %v", entry, err)
+		}
+	})
+}
+
+func TestControlPlaneSecurityOptOut(t *testing.T) {
+	archive, err := lambdatest.Archive(map[string]string{"main.go": "package main\n"})
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	spec := &proto.FunctionSpec{Function: &proto.FunctionRef{Name: "hello"}, SecurityOptOut: []string{"read_only"}}
+
+	for _, tt := range []struct {
+		hardening string
+		code      codes.Code
+	}{
+		{hardening: "false", code: codes.InvalidArgument},
+		{hardening: "true", code: codes.OK},
+	} {
+		t.Run("hardening "+tt.hardening, func(t *testing.T) {
+			rt := lambdatest.NewRuntime()
+			rt.Handle("hello", func(context.Context, []byte) ([]byte, error) {
+				return nil, nil
+			})
+
+			h := lambdatest.New(t, rt, map[string]string{"SECURITY_HARDENING": tt.hardening})
+
+			fn, err := proto.CreateFunction(context.Background(), controlPlane(t, h), spec, bytes.NewReader(archive))
+			if status.Code(err) != tt.code {
+				t.Fatalf("create function: %v", err)
+			}
+
+			if err == nil && (len(fn.GetSecurityOptOut()) != 1 || fn.GetSecurityOptOut()[0] != "read_only") {
+				t.Fatalf("unexpected function %v", fn)
+			}
+		})
+	}
+}
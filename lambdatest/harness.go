@@ -0,0 +1,199 @@
+package lambdatest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/sethvargo/go-envconfig"
+
+	"github.com/ihippik/lambda-go/config"
+	"github.com/ihippik/lambda-go/lambda"
+)
+
+// Harness is the control plane API served on the ephemeral loopback port with the lambdatest runtime.
+type Harness struct {
+	// URL is the base URL of the API, e.g. "http://127.0.0.1:54321".
+	URL      string
+	Config   *config.Config
+	Runtime  *Runtime
+	Service  *lambda.Service
+	Endpoint *lambda.Endpoint
+
+	dir    string
+	cancel context.CancelFunc
+	done   chan error
+}
+
+// Start starts the control plane with the runtime.
+// Configuration is read from env as from the environment, e.g. "APP_MAX_CONCURRENCY": "1",
+// the harness sets the listen address, host-port network mode and temporary build dir and audit file.
+func Start(rt *Runtime, env map[string]string) (*Harness, error) {
+	dir, err := os.MkdirTemp("", "lambdatest")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+
+	h, err := start(rt, env, dir)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func start(rt *Runtime, env map[string]string, dir string) (*Harness, error) {
+	vars := map[string]string{
+		"LOG_LEVEL":          "error",
+		"APP_SERVER_ADDR":    "127.0.0.1:0",
+		"APP_NETWORK_MODE":   "host-port",
+		"APP_BUILD_DIR":      filepath.Join(dir, "build"),
+		"APP_AUDIT_FILE":     filepath.Join(dir, "audit.jsonl"),
+		"AUTH_KEYS_FILE":     filepath.Join(dir, "api_keys.json"),
+		"APP_ROUTES_FILE":    filepath.Join(dir, "routes.json"),
+		"APP_QUOTAS_FILE":    filepath.Join(dir, "quotas.json"),
+		"TLS_CA_DIR":         filepath.Join(dir, "ca"),
+		"APP_RUNTIME_DIR":    filepath.Join(dir, "runtime"),
+		"APP_PORT_RANGE_MIN": "30000",
+		"APP_PORT_RANGE_MAX": "39999",
+	}
+
+	for k, v := range env {
+		vars[k] = v
+	}
+
+	var cfg config.Config
+
+	if err := envconfig.ProcessWith(context.Background(), &cfg, envconfig.MapLookuper(vars)); err != nil {
+		return nil, fmt.Errorf("process env: %w", err)
+	}
+
+	if err := os.MkdirAll(cfg.App.BuildDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create build dir: %w", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	svc, err := lambda.NewService(&cfg, logger, rt)
+	if err != nil {
+		return nil, fmt.Errorf("new service: %w", err)
+	}
+
+	edp, err := lambda.NewEndpoint(&cfg, svc, logger)
+	if err != nil {
+		return nil, fmt.Errorf("new endpoint: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if err := svc.Init(ctx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("init service: %w", err)
+	}
+
+	lis, err := net.Listen("tcp", cfg.App.ServerAddr)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	h := &Harness{
+		URL:      "http://" + lis.Addr().String(),
+		Config:   &cfg,
+		Runtime:  rt,
+		Service:  svc,
+		Endpoint: edp,
+		dir:      dir,
+		cancel:   cancel,
+		done:     make(chan error, 1),
+	}
+
+	go func() {
+		h.done <- edp.Serve(ctx, lis)
+	}()
+
+	return h, nil
+}
+
+// New starts the harness for the test, it is closed on the test cleanup.
+func New(t testing.TB, rt *Runtime, env map[string]string) *Harness {
+	t.Helper()
+
+	h, err := Start(rt, env)
+	if err != nil {
+		t.Fatalf("lambdatest: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := h.Close(); err != nil {
+			t.Errorf("lambdatest: close: %v", err)
+		}
+	})
+
+	return h
+}
+
+// Close stops the API server and running containers and removes temporary files.
+func (h *Harness) Close() error {
+	h.cancel()
+
+	err := <-h.done
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+
+	h.Runtime.Close()
+
+	return errors.Join(err, os.RemoveAll(h.dir))
+}
+
+// Archive returns tar.gz archive with the files to upload as the function source.
+// The archive is uploaded as the "file" form field with "application/gzip" content type.
+// Sources are not built by lambdatest runtime, so the content matters to the tested tooling only.
+func Archive(files map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+
+		if _, err := io.WriteString(tw, files[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,236 @@
+package lambdatest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"testing"
+
+	"github.com/ihippik/lambda-go/lambda"
+	"github.com/ihippik/lambda-go/lambdatest"
+)
+
+// upload posts the function archive to the create or update path and returns the response status.
+func upload(t *testing.T, h *lambdatest.Harness, path string) int {
+	t.Helper()
+
+	archive, err := lambdatest.Archive(map[string]string{"main.go": "package main\n"})
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+
+	part := textproto.MIMEHeader{}
+	part.Set("Content-Disposition", `form-data; name="file"; filename="func.tar.gz"`)
+	part.Set("Content-Type", "application/gzip")
+
+	w, err := mw.CreatePart(part)
+	if err != nil {
+		t.Fatalf("create part: %v", err)
+	}
+
+	if _, err := w.Write(archive); err != nil {
+		t.Fatalf("write part: %v", err)
+	}
+
+	if err := mw.Close(); err != nil {
+		t.Fatalf("close form: %v", err)
+	}
+
+	resp, err := http.Post(h.URL+path, mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+// do sends the request and returns the response status and body.
+func do(t *testing.T, method, url, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+
+	return resp.StatusCode, string(data)
+}
+
+func TestHarnessCreateInvokeDelete(t *testing.T) {
+	rt := lambdatest.NewRuntime()
+	rt.Handle("hello", func(_ context.Context, payload []byte) ([]byte, error) {
+		return append([]byte("hello "), payload...), nil
+	})
+
+	h := lambdatest.New(t, rt, nil)
+
+	if status := upload(t, h, "/lambda/hello/create"); status != http.StatusCreated {
+		t.Fatalf("create status %d", status)
+	}
+
+	if builds := rt.Builds(); len(builds) != 1 || builds[0] != lambda.ImageTag("hello") {
+		t.Fatalf("unexpected builds %v", builds)
+	}
+
+	status, body := do(t, http.MethodPost, h.URL+"/lambda/hello/invoke", "Ivan")
+	if status != http.StatusOK || body != "hello Ivan" {
+		t.Fatalf("invoke: %d %q", status, body)
+	}
+
+	status, body = do(t, http.MethodGet, h.URL+"/lambda", "")
+	if status != http.StatusOK {
+		t.Fatalf("list: %d %q", status, body)
+	}
+
+	var functions []lambda.FunctionInfo
+
+	if err := json.Unmarshal([]byte(body), &functions); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+
+	if len(functions) != 1 || functions[0].Name != "hello" || functions[0].Namespace != lambda.DefaultNamespace {
+		t.Fatalf("unexpected functions %+v", functions)
+	}
+
+	if status, body := do(t, http.MethodDelete, h.URL+"/lambda/hello", ""); status != http.StatusNoContent {
+		t.Fatalf("delete: %d %q", status, body)
+	}
+
+	if status, _ := do(t, http.MethodPost, h.URL+"/lambda/hello/invoke", "Ivan"); status != http.StatusNotFound {
+		t.Fatalf("invoke deleted function: %d", status)
+	}
+
+	if n := rt.Running(); n != 0 {
+		t.Fatalf("%d containers are running after delete", n)
+	}
+}
+
+func TestHarnessNamespace(t *testing.T) {
+	rt := lambdatest.NewRuntime()
+	rt.Handle("team-a/hello", func(context.Context, []byte) ([]byte, error) {
+		return []byte("team-a"), nil
+	})
+
+	h := lambdatest.New(t, rt, nil)
+
+	if status := upload(t, h, "/ns/team-a/lambda/hello/create"); status != http.StatusCreated {
+		t.Fatalf("create status %d", status)
+	}
+
+	if status, body := do(t, http.MethodPost, h.URL+"/ns/team-a/lambda/hello/invoke", ""); body != "team-a" {
+		t.Fatalf("invoke: %d %q", status, body)
+	}
+
+	if status, _ := do(t, http.MethodPost, h.URL+"/lambda/hello/invoke", ""); status != http.StatusNotFound {
+		t.Fatalf("invoke in default namespace: %d", status)
+	}
+}
+
+func TestHarnessNoHandler(t *testing.T) {
+	h := lambdatest.New(t, lambdatest.NewRuntime(), nil)
+
+	if status := upload(t, h, "/lambda/missing/create"); status != http.StatusBadRequest {
+		t.Fatalf("create status %d", status)
+	}
+}
+
+func TestHarnessHandlerError(t *testing.T) {
+	var calls int
+
+	rt := lambdatest.NewRuntime()
+	rt.Handle("fail", func(context.Context, []byte) ([]byte, error) {
+		calls++
+		return nil, errors.New("boom")
+	})
+
+	h := lambdatest.New(t, rt, nil)
+
+	if status := upload(t, h, "/lambda/fail/create"); status != http.StatusCreated {
+		t.Fatalf("create status %d", status)
+	}
+
+	status, body := do(t, http.MethodPost, h.URL+"/lambda/fail/invoke", "")
+	if status != http.StatusBadRequest || !bytes.Contains([]byte(body), []byte("boom")) {
+		t.Fatalf("invoke: %d %q", status, body)
+	}
+
+	// the request is sent once, so the handler side effects are not repeated.
+	if calls != 1 {
+		t.Fatalf("handler is called %d times", calls)
+	}
+}
+
+func TestHarnessUpdate(t *testing.T) {
+	rt := lambdatest.NewRuntime()
+	rt.Handle("hello", func(context.Context, []byte) ([]byte, error) {
+		return []byte("v1"), nil
+	})
+
+	h := lambdatest.New(t, rt, nil)
+
+	if status := upload(t, h, "/lambda/hello/update"); status != http.StatusNotFound {
+		t.Fatalf("update unknown function: %d", status)
+	}
+
+	if status := upload(t, h, "/lambda/hello/create"); status != http.StatusCreated {
+		t.Fatalf("create status %d", status)
+	}
+
+	rt.Handle("hello", func(context.Context, []byte) ([]byte, error) {
+		return []byte("v2"), nil
+	})
+
+	if status := upload(t, h, "/lambda/hello/update"); status != http.StatusOK {
+		t.Fatalf("update status %d", status)
+	}
+
+	if status, body := do(t, http.MethodPost, h.URL+"/lambda/hello/invoke", ""); body != "v2" {
+		t.Fatalf("invoke: %d %q", status, body)
+	}
+
+	if builds := rt.Builds(); len(builds) != 2 {
+		t.Fatalf("unexpected builds %v", builds)
+	}
+}
+
+func TestHarnessCreateExisting(t *testing.T) {
+	rt := lambdatest.NewRuntime()
+	rt.Handle("hello", func(context.Context, []byte) ([]byte, error) {
+		return nil, nil
+	})
+
+	h := lambdatest.New(t, rt, nil)
+
+	if status := upload(t, h, "/lambda/hello/create"); status != http.StatusCreated {
+		t.Fatalf("create status %d", status)
+	}
+
+	if status := upload(t, h, "/lambda/hello/create"); status != http.StatusConflict {
+		t.Fatalf("create existing function: %d", status)
+	}
+
+	if builds := rt.Builds(); len(builds) != 1 {
+		t.Fatalf("unexpected builds %v", builds)
+	}
+}
@@ -0,0 +1,348 @@
+// Package lambdatest runs the lambda-go control plane without Docker for integration tests.
+// Runtime is the in-memory backend which serves Go handlers in-process,
+// Harness serves the control plane API with it on the ephemeral port.
+package lambdatest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/ihippik/lambda-go/builder"
+	"github.com/ihippik/lambda-go/lambda"
+)
+
+// imagePrefix is the prefix of the fake images, image name is the function image tag.
+const imagePrefix = "lambdatest:"
+
+// ErrNoHandler is returned by the build of the function without registered handler.
+var ErrNoHandler = errors.New("no handler registered")
+
+// Runtime is the in-memory lambda.Runtime which runs registered handlers in-process.
+// Every started container is the real function gRPC server on its loopback port,
+// so the control plane invokes it the same way as the container.
+type Runtime struct {
+	mu         sync.Mutex
+	servers    map[string]*lambda.Server
+	containers map[string]*container
+	builds     []string
+}
+
+type container struct {
+	opts builder.ContainerOptions
+	srv  *grpc.Server
+	// done is closed when the running server is stopped.
+	done chan struct{}
+	// output receives the lines written by Log, they are kept in pending until the logs are followed.
+	output  func(stream, line string)
+	pending [][2]string
+}
+
+// NewRuntime returns empty Runtime.
+func NewRuntime() *Runtime {
+	return &Runtime{
+		servers:    make(map[string]*lambda.Server),
+		containers: make(map[string]*container),
+	}
+}
+
+// Handle registers the handler of the function, function is "namespace/name" or the name in the default namespace.
+// Function could be created only after its handler is registered, the handler is used by the next container start.
+func (r *Runtime) Handle(function string, handler lambda.Handler) {
+	r.register(function, lambda.NewServer(handler))
+}
+
+// HandleStream registers the streaming handler of the function.
+func (r *Runtime) HandleStream(function string, handler lambda.StreamHandler) {
+	r.register(function, lambda.NewStreamingServer(handler))
+}
+
+func (r *Runtime) register(function string, srv *lambda.Server) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.servers[lambda.ImageTag(function)] = srv
+}
+
+// Log writes the line to the stream ("stdout" or "stderr") of the running containers of the function,
+// so the handler output is captured by the control plane as the container output.
+func (r *Runtime) Log(function, stream, line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	image := imagePrefix + lambda.ImageTag(function)
+
+	for _, c := range r.containers {
+		if c.opts.Image != image || !c.running() {
+			continue
+		}
+
+		if c.output != nil {
+			c.output(stream, line)
+			continue
+		}
+
+		c.pending = append(c.pending, [2]string{stream, line})
+	}
+}
+
+// Builds returns image tags of the functions built by the runtime in build order.
+func (r *Runtime) Builds() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.builds...)
+}
+
+// Running returns the number of running containers.
+func (r *Runtime) Running() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int
+
+	for _, c := range r.containers {
+		if c.running() {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Close stops all running containers.
+func (r *Runtime) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.containers {
+		c.stop()
+	}
+}
+
+// ImageBuild returns the image of the function, the build fails if its handler is not registered.
+func (r *Runtime) ImageBuild(_ context.Context, _ string, name string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.servers[name]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrNoHandler, name)
+	}
+
+	r.builds = append(r.builds, name)
+
+	return imagePrefix + name, nil
+}
+
+// ContainerCreate creates the container of the function image, only host-port network mode is supported.
+func (r *Runtime) ContainerCreate(_ context.Context, opts builder.ContainerOptions) (string, error) {
+	if opts.Network != "" {
+		return "", errors.New("networks are not supported by lambdatest runtime")
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	id := hex.EncodeToString(b)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.containers[id] = &container{opts: opts}
+
+	return id, nil
+}
+
+// ContainerStart serves the function handler on the container port.
+func (r *Runtime) ContainerStart(_ context.Context, containerID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.containers[containerID]
+	if !ok {
+		return fmt.Errorf("container %s not found", containerID)
+	}
+
+	if c.running() {
+		return nil
+	}
+
+	handler, ok := r.servers[strings.TrimPrefix(c.opts.Image, imagePrefix)]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoHandler, c.opts.Image)
+	}
+
+	lis, err := net.Listen("tcp", net.JoinHostPort(c.opts.HostIP, strconv.Itoa(c.opts.Port)))
+	if err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	c.srv = grpc.NewServer()
+	c.done = make(chan struct{})
+	c.pending = nil
+
+	handler.Register(c.srv)
+
+	go func(srv *grpc.Server, done chan struct{}) {
+		defer close(done)
+		_ = srv.Serve(lis)
+	}(c.srv, c.done)
+
+	return nil
+}
+
+// ContainerStop stops the function server.
+func (r *Runtime) ContainerStop(_ context.Context, containerID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.containers[containerID]
+	if !ok {
+		return fmt.Errorf("container %s not found", containerID)
+	}
+
+	c.stop()
+
+	return nil
+}
+
+// ContainerRemove stops and removes the container.
+func (r *Runtime) ContainerRemove(_ context.Context, containerID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.containers[containerID]
+	if !ok {
+		return fmt.Errorf("container %s not found", containerID)
+	}
+
+	c.stop()
+	delete(r.containers, containerID)
+
+	return nil
+}
+
+// ContainersList lists all containers.
+func (r *Runtime) ContainersList(_ context.Context) ([]builder.Container, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]builder.Container, 0, len(r.containers))
+
+	for id, c := range r.containers {
+		result = append(result, c.container(id))
+	}
+
+	return result, nil
+}
+
+// ContainerInspect inspects the container.
+func (r *Runtime) ContainerInspect(_ context.Context, containerID string) (builder.Container, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.containers[containerID]
+	if !ok {
+		return builder.Container{}, fmt.Errorf("container %s not found", containerID)
+	}
+
+	return c.container(containerID), nil
+}
+
+// ContainerLogs follows the lines written by Log until the container is stopped or context is canceled.
+// Handlers run in the test process, so their own stdout and stderr are not collected.
+func (r *Runtime) ContainerLogs(ctx context.Context, containerID string, _ time.Time, fn func(stream, line string)) error {
+	r.mu.Lock()
+
+	c, ok := r.containers[containerID]
+	if !ok {
+		r.mu.Unlock()
+		return fmt.Errorf("container %s not found", containerID)
+	}
+
+	for _, line := range c.pending {
+		fn(line[0], line[1])
+	}
+
+	c.pending = nil
+	done := c.done
+
+	if done == nil {
+		r.mu.Unlock()
+		return nil
+	}
+
+	c.output = fn
+
+	r.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	r.mu.Lock()
+	// the restarted container is followed by the next call.
+	if c.done == done {
+		c.output = nil
+	}
+	r.mu.Unlock()
+
+	return nil
+}
+
+// NetworkEnsure is a no-op, handlers listen on the loopback.
+func (r *Runtime) NetworkEnsure(context.Context, string, bool, bool) error {
+	return nil
+}
+
+// NetworkGateway is not supported by lambdatest runtime.
+func (r *Runtime) NetworkGateway(context.Context, string) (string, error) {
+	return "", errors.New("networks are not supported by lambdatest runtime")
+}
+
+func (c *container) running() bool {
+	if c.done == nil {
+		return false
+	}
+
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *container) stop() {
+	if c.running() {
+		c.srv.Stop()
+		<-c.done
+	}
+}
+
+func (c *container) container(id string) builder.Container {
+	return builder.Container{
+		ID:        id,
+		Name:      c.opts.Name,
+		Image:     c.opts.Image,
+		Running:   c.running(),
+		Labels:    c.opts.Labels,
+		Env:       c.opts.Env,
+		MemoryMB:  c.opts.MemoryMB,
+		HostIP:    c.opts.HostIP,
+		Port:      c.opts.Port,
+		Hardening: c.opts.Hardening,
+	}
+}
@@ -0,0 +1,83 @@
+package lambdatest_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ihippik/lambda-go/lambdatest"
+)
+
+func TestInvokeStreamWriteTimeout(t *testing.T) {
+	next := make(chan struct{})
+
+	rt := lambdatest.NewRuntime()
+	rt.HandleStream("counter", func(_ context.Context, _ []byte, w io.Writer) error {
+		for _, chunk := range []string{"one\n", "two\n", "three\n"} {
+			if _, err := io.WriteString(w, chunk); err != nil {
+				return err
+			}
+
+			// the next chunk is written after the client has read the previous one.
+			<-next
+		}
+
+		return nil
+	})
+	rt.Handle("hello", func(context.Context, []byte) ([]byte, error) {
+		return []byte("hello"), nil
+	})
+
+	h := lambdatest.New(t, rt, nil)
+
+	for _, name := range []string{"counter", "hello"} {
+		if status := upload(t, h, "/lambda/"+name+"/create"); status != http.StatusCreated {
+			t.Fatalf("create %s status %d", name, status)
+		}
+	}
+
+	// write deadline of the server has already passed when the handler starts,
+	// so only the response which resets it is written.
+	srv := httptest.NewUnstartedServer(h.Endpoint)
+	srv.Config.WriteTimeout = time.Nanosecond
+	srv.Start()
+	defer srv.Close()
+
+	if resp, err := http.Post(srv.URL+"/lambda/hello/invoke", "application/json", nil); err == nil {
+		resp.Body.Close()
+		t.Fatalf("response is written after write deadline: %d", resp.StatusCode)
+	}
+
+	resp, err := http.Post(srv.URL+"/lambda/counter/invoke-stream", "application/json", nil)
+	if err != nil {
+		t.Fatalf("invoke stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("invoke stream status %d", resp.StatusCode)
+	}
+
+	body := bufio.NewReader(resp.Body)
+
+	for i, want := range []string{"one\n", "two\n", "three\n"} {
+		line, err := body.ReadString('\n')
+		if err != nil || line != want {
+			t.Fatalf("chunk %d: %q %v", i, line, err)
+		}
+
+		next <- struct{}{}
+	}
+
+	if rest, err := io.ReadAll(body); err != nil || len(rest) != 0 {
+		t.Fatalf("read stream end: %q %v", rest, err)
+	}
+
+	if trailer := resp.Trailer.Get("X-Lambda-Error"); trailer != "" {
+		t.Fatalf("stream failed: %s", trailer)
+	}
+}
@@ -0,0 +1,148 @@
+package lambdatest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ihippik/lambda-go/lambda"
+	"github.com/ihippik/lambda-go/lambdatest"
+)
+
+func TestWebSocket(t *testing.T) {
+	events := make(chan lambda.WebSocketEvent, 10)
+
+	rt := lambdatest.NewRuntime()
+	rt.Handle("chat", func(_ context.Context, payload []byte) ([]byte, error) {
+		var event lambda.WebSocketEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+
+		events <- event
+
+		if event.Type == lambda.WebSocketMessage {
+			return []byte("echo: " + event.Body), nil
+		}
+
+		return nil, nil
+	})
+
+	h := lambdatest.New(t, rt, nil)
+
+	if status := upload(t, h, "/lambda/chat/create"); status != http.StatusCreated {
+		t.Fatalf("create status %d", status)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(h.URL, "http")+"/lambda/chat/ws", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	next := func(eventType string) lambda.WebSocketEvent {
+		t.Helper()
+
+		select {
+		case event := <-events:
+			if event.Type != eventType {
+				t.Fatalf("unexpected event %+v, want %s", event, eventType)
+			}
+
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s event", eventType)
+		}
+
+		return lambda.WebSocketEvent{}
+	}
+
+	read := func() string {
+		t.Helper()
+
+		if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatalf("set read deadline: %v", err)
+		}
+
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+
+		return string(msg)
+	}
+
+	id := next(lambda.WebSocketConnect).ConnectionID
+
+	// client message is forwarded to the function, its response is sent back.
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hi")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if event := next(lambda.WebSocketMessage); event.ConnectionID != id || event.Body != "hi" {
+		t.Fatalf("unexpected message event %+v", event)
+	}
+
+	if msg := read(); msg != "echo: hi" {
+		t.Fatalf("unexpected response %q", msg)
+	}
+
+	// the function pushes messages to the connection through the API.
+	if err := lambda.PostToConnection(context.Background(), h.URL, id, []byte("push")); err != nil {
+		t.Fatalf("post to connection: %v", err)
+	}
+
+	if msg := read(); msg != "push" {
+		t.Fatalf("unexpected pushed message %q", msg)
+	}
+
+	if err := lambda.PostToConnection(context.Background(), h.URL, "unknown", []byte("push")); !errors.Is(err, lambda.ErrConnectionNotFound) {
+		t.Fatalf("post to unknown connection: %v", err)
+	}
+
+	// closed connection emits disconnect event and is not found anymore.
+	if status, _ := do(t, http.MethodDelete, h.URL+"/connections/"+id, ""); status != http.StatusNoContent {
+		t.Fatalf("delete connection status %d", status)
+	}
+
+	if event := next(lambda.WebSocketDisconnect); event.ConnectionID != id {
+		t.Fatalf("unexpected disconnect event %+v", event)
+	}
+
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("connection is open after delete")
+	}
+
+	if err := lambda.PostToConnection(context.Background(), h.URL, id, []byte("push")); !errors.Is(err, lambda.ErrConnectionNotFound) {
+		t.Fatalf("post to closed connection: %v", err)
+	}
+}
+
+func TestWebSocketConnectRejected(t *testing.T) {
+	rt := lambdatest.NewRuntime()
+	rt.Handle("chat", func(context.Context, []byte) ([]byte, error) {
+		return nil, errors.New("unauthorized")
+	})
+
+	h := lambdatest.New(t, rt, nil)
+
+	if status := upload(t, h, "/lambda/chat/create"); status != http.StatusCreated {
+		t.Fatalf("create status %d", status)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(h.URL, "http")+"/lambda/chat/ws", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseInternalServerErr) {
+		t.Fatalf("unexpected read error %v", err)
+	}
+}